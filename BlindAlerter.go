@@ -1,9 +1,11 @@
 package poker
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -17,8 +19,329 @@ func (a BlindAlerterFunc) ScheduledAlertAt(duration time.Duration, amount int, t
 	a(duration, amount, to)
 }
 
+// BlindWarner is implemented by alerters that can also give advance notice
+// of an upcoming blind level.
+type BlindWarner interface {
+	ScheduledWarningAt(duration time.Duration, warning BlindWarning, to io.Writer)
+}
+
+// BlindWarning says the blinds go up to Amount In a while from now.
+type BlindWarning struct {
+	Amount int
+	In     time.Duration
+}
+
+// AlertCanceller is implemented by alerters that can withdraw the alerts
+// they have scheduled but not yet delivered.
+type AlertCanceller interface {
+	CancelAlerts()
+}
+
+// ClockStatus describes the blind clock after it has been paused, resumed or
+// otherwise adjusted.
+type ClockStatus struct {
+	Paused     bool
+	Amount     int
+	NextAmount int
+	Remaining  time.Duration
+}
+
+// StatusReporter is implemented by alerters that can tell players about
+// changes to the blind clock as they happen.
+type StatusReporter interface {
+	ReportStatus(status ClockStatus, to io.Writer)
+}
+
+// AlertSink is somewhere a blind alert ends up once it is due.
+type AlertSink interface {
+	Alert(amount int, to io.Writer)
+}
+
+type AlertSinkFunc func(amount int, to io.Writer)
+
+func (a AlertSinkFunc) Alert(amount int, to io.Writer) {
+	a(amount, to)
+}
+
+// WarningSink is implemented by sinks that want to hear about upcoming levels
+// as well as the level changes themselves.
+type WarningSink interface {
+	Warn(warning BlindWarning, to io.Writer)
+}
+
+// StatusSink is implemented by sinks that want to hear about changes to the
+// blind clock.
+type StatusSink interface {
+	Status(status ClockStatus, to io.Writer)
+}
+
+// ScheduledAlerter waits out each alert's duration and then fans it out to
+// every one of its sinks.
+type ScheduledAlerter struct {
+	clock Clock
+	sinks []AlertSink
+
+	mu      sync.Mutex
+	pending map[Timer]struct{}
+}
+
+func NewScheduledAlerter(sinks ...AlertSink) *ScheduledAlerter {
+	return NewScheduledAlerterWithClock(SystemClock, sinks...)
+}
+
+func NewScheduledAlerterWithClock(clock Clock, sinks ...AlertSink) *ScheduledAlerter {
+	return &ScheduledAlerter{
+		clock:   clock,
+		sinks:   sinks,
+		pending: map[Timer]struct{}{},
+	}
+}
+
+func (s *ScheduledAlerter) ScheduledAlertAt(duration time.Duration, amount int, to io.Writer) {
+	s.schedule(duration, func(sink AlertSink) {
+		sink.Alert(amount, to)
+	})
+}
+
+func (s *ScheduledAlerter) ScheduledWarningAt(duration time.Duration, warning BlindWarning, to io.Writer) {
+	s.schedule(duration, func(sink AlertSink) {
+		if warner, ok := sink.(WarningSink); ok {
+			warner.Warn(warning, to)
+		}
+	})
+}
+
+func (s *ScheduledAlerter) ReportStatus(status ClockStatus, to io.Writer) {
+	for _, sink := range s.sinks {
+		if reporter, ok := sink.(StatusSink); ok {
+			reporter.Status(status, to)
+		}
+	}
+}
+
+func (s *ScheduledAlerter) CancelAlerts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for timer := range s.pending {
+		timer.Stop()
+	}
+	s.pending = map[Timer]struct{}{}
+}
+
+func (s *ScheduledAlerter) schedule(duration time.Duration, deliver func(sink AlertSink)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var timer Timer
+	timer = s.clock.AfterFunc(duration, func() {
+		s.mu.Lock()
+		delete(s.pending, timer)
+		s.mu.Unlock()
+
+		for _, sink := range s.sinks {
+			deliver(sink)
+		}
+	})
+	s.pending[timer] = struct{}{}
+}
+
+// MultiAlerter passes every alert on to each of its alerters.
+type MultiAlerter []BlindAlerter
+
+func NewMultiAlerter(alerters ...BlindAlerter) MultiAlerter {
+	return MultiAlerter(alerters)
+}
+
+func (m MultiAlerter) ScheduledAlertAt(duration time.Duration, amount int, to io.Writer) {
+	for _, alerter := range m {
+		alerter.ScheduledAlertAt(duration, amount, to)
+	}
+}
+
+func (m MultiAlerter) ScheduledWarningAt(duration time.Duration, warning BlindWarning, to io.Writer) {
+	for _, alerter := range m {
+		if warner, ok := alerter.(BlindWarner); ok {
+			warner.ScheduledWarningAt(duration, warning, to)
+		}
+	}
+}
+
+func (m MultiAlerter) ReportStatus(status ClockStatus, to io.Writer) {
+	for _, alerter := range m {
+		if reporter, ok := alerter.(StatusReporter); ok {
+			reporter.ReportStatus(status, to)
+		}
+	}
+}
+
+func (m MultiAlerter) CancelAlerts() {
+	for _, alerter := range m {
+		if canceller, ok := alerter.(AlertCanceller); ok {
+			canceller.CancelAlerts()
+		}
+	}
+}
+
+type writerSink struct{}
+
+func (writerSink) Alert(amount int, to io.Writer) {
+	fmt.Fprintf(to, "Blind is now %d\n", amount)
+}
+
+func (writerSink) Warn(warning BlindWarning, to io.Writer) {
+	fmt.Fprintf(to, "Blinds go up to %d in %v\n", warning.Amount, warning.In)
+}
+
+func (writerSink) Status(status ClockStatus, to io.Writer) {
+	if status.Paused {
+		fmt.Fprintf(to, "Clock paused at blind %d with %v left in the level\n", status.Amount, status.Remaining)
+		return
+	}
+	fmt.Fprintf(to, "Clock running at blind %d with %v left in the level\n", status.Amount, status.Remaining)
+}
+
+type stdOutSink struct{}
+
+func (stdOutSink) Alert(amount int, to io.Writer) {
+	writerSink{}.Alert(amount, os.Stdout)
+}
+
+func (stdOutSink) Warn(warning BlindWarning, to io.Writer) {
+	writerSink{}.Warn(warning, os.Stdout)
+}
+
+func (stdOutSink) Status(status ClockStatus, to io.Writer) {
+	writerSink{}.Status(status, os.Stdout)
+}
+
+type jsonSink struct{}
+
+func (jsonSink) Alert(amount int, to io.Writer) {
+	to.Write(newBlindAlertMessage("blind", amount, 0))
+}
+
+func (jsonSink) Warn(warning BlindWarning, to io.Writer) {
+	to.Write(newBlindAlertMessage("warning", warning.Amount, warning.In))
+}
+
+func (jsonSink) Status(status ClockStatus, to io.Writer) {
+	to.Write(newClockStatusMessage(status))
+}
+
+var (
+	WriterSink AlertSink = writerSink{}
+	StdOutSink AlertSink = stdOutSink{}
+	// JSONSink writes each alert to the game's writer as a BlindAlertMessage,
+	// which is what game.html expects over its WebSocket.
+	JSONSink AlertSink = jsonSink{}
+)
+
+var BellSink = AlertSinkFunc(func(amount int, to io.Writer) {
+	fmt.Fprint(to, "\a")
+})
+
+type loggerSink struct {
+	logger *Logger
+}
+
+// LoggerSink logs blind changes and warnings to logger.
+func LoggerSink(logger *Logger) AlertSink {
+	return loggerSink{logger}
+}
+
+func (l loggerSink) Alert(amount int, to io.Writer) {
+	l.logger.Info("blind changed", "amount", amount)
+}
+
+func (l loggerSink) Warn(warning BlindWarning, to io.Writer) {
+	l.logger.Info("blind warning", "amount", warning.Amount, "in", warning.In)
+}
+
 func Alerter(duration time.Duration, amount int, to io.Writer) {
-	time.AfterFunc(duration, func() {
-		fmt.Fprintf(os.Stdout, "Blind is now %d/n", amount)
+	NewScheduledAlerter(WriterSink).ScheduledAlertAt(duration, amount, to)
+}
+
+func StdOutAlerter(duration time.Duration, amount int, to io.Writer) {
+	NewScheduledAlerter(StdOutSink).ScheduledAlertAt(duration, amount, to)
+}
+
+type BlindAlertMessage struct {
+	Type   string
+	Amount int
+	// Seconds until Amount takes effect, for warnings, or left in the level
+	// for clock messages.
+	In     int  `json:",omitempty"`
+	Next   int  `json:",omitempty"`
+	Paused bool `json:",omitempty"`
+}
+
+func newBlindAlertMessage(kind string, amount int, in time.Duration) []byte {
+	msg, _ := json.Marshal(BlindAlertMessage{Type: kind, Amount: amount, In: int(in / time.Second)})
+	return msg
+}
+
+func newClockStatusMessage(status ClockStatus) []byte {
+	msg, _ := json.Marshal(BlindAlertMessage{
+		Type:   "clock",
+		Amount: status.Amount,
+		In:     int(status.Remaining / time.Second),
+		Next:   status.NextAmount,
+		Paused: status.Paused,
 	})
+	return msg
+}
+
+// Broadcaster sends every alert as JSON to all the writers currently
+// registered with it, such as the WebSocket connections of people watching
+// the game.
+type Broadcaster struct {
+	mu      sync.Mutex
+	writers map[io.Writer]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{writers: map[io.Writer]struct{}{}}
+}
+
+func (b *Broadcaster) Add(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writers[w] = struct{}{}
+}
+
+func (b *Broadcaster) Remove(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.writers, w)
+}
+
+// Len is how many writers are registered.
+func (b *Broadcaster) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.writers)
+}
+
+func (b *Broadcaster) Alert(amount int, to io.Writer) {
+	b.broadcast(newBlindAlertMessage("blind", amount, 0))
+}
+
+func (b *Broadcaster) Warn(warning BlindWarning, to io.Writer) {
+	b.broadcast(newBlindAlertMessage("warning", warning.Amount, warning.In))
+}
+
+func (b *Broadcaster) Status(status ClockStatus, to io.Writer) {
+	b.broadcast(newClockStatusMessage(status))
+}
+
+func (b *Broadcaster) broadcast(msg []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for w := range b.writers {
+		if _, err := w.Write(msg); err != nil {
+			DefaultLogger.Warn("problem broadcasting blind alert", "err", err)
+		}
+	}
 }
@@ -0,0 +1,155 @@
+package poker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduledAlerter(t *testing.T) {
+	t.Run("fans an alert out to every sink", func(t *testing.T) {
+		first, second := newSpyAlertSink(), newSpyAlertSink()
+		alerter := NewScheduledAlerter(first, second)
+
+		alerter.ScheduledAlertAt(0, 200, io.Discard)
+
+		assertAlertReceived(t, first, 200)
+		assertAlertReceived(t, second, 200)
+	})
+	t.Run("only passes warnings to sinks that want them", func(t *testing.T) {
+		warned := make(chan BlindWarning, 1)
+		alerter := NewScheduledAlerter(BellSink, spyWarningSink(warned))
+
+		alerter.ScheduledWarningAt(0, BlindWarning{Amount: 300, In: time.Minute}, io.Discard)
+
+		select {
+		case got := <-warned:
+			if got.Amount != 300 {
+				t.Errorf("got warning for %d, want 300", got.Amount)
+			}
+		case <-time.After(time.Second):
+			t.Error("timed out waiting for warning")
+		}
+	})
+	t.Run("cancelled alerts are never delivered", func(t *testing.T) {
+		sink := newSpyAlertSink()
+		clock := NewFakeClock()
+		alerter := NewScheduledAlerterWithClock(clock, sink)
+
+		alerter.ScheduledAlertAt(time.Minute, 200, io.Discard)
+		alerter.ScheduledAlertAt(2*time.Minute, 300, io.Discard)
+		clock.Advance(time.Minute)
+		assertAlertReceived(t, sink, 200)
+
+		alerter.CancelAlerts()
+		clock.Advance(time.Hour)
+
+		if len(sink) != 0 {
+			t.Errorf("got alert for %d after cancelling", <-sink)
+		}
+	})
+	t.Run("multi alerter schedules on every alerter", func(t *testing.T) {
+		first, second := newSpyAlertSink(), newSpyAlertSink()
+		alerter := NewMultiAlerter(NewScheduledAlerter(first), NewScheduledAlerter(second))
+
+		alerter.ScheduledAlertAt(0, 400, io.Discard)
+
+		assertAlertReceived(t, first, 400)
+		assertAlertReceived(t, second, 400)
+	})
+}
+
+func TestAlertSinks(t *testing.T) {
+	t.Run("writer sink writes to the game's writer", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		WriterSink.Alert(100, out)
+
+		assertResponseBody(t, out.String(), "Blind is now 100\n")
+	})
+	t.Run("writer sink warns about the next level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		WriterSink.(WarningSink).Warn(BlindWarning{Amount: 200, In: time.Minute}, out)
+
+		assertResponseBody(t, out.String(), "Blinds go up to 200 in 1m0s\n")
+	})
+	t.Run("json sink writes messages for game.html", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		JSONSink.(WarningSink).Warn(BlindWarning{Amount: 200, In: 10 * time.Second}, out)
+
+		assertResponseBody(t, out.String(), `{"Type":"warning","Amount":200,"In":10}`)
+	})
+	t.Run("bell sink rings the terminal bell", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		BellSink.Alert(100, out)
+
+		assertResponseBody(t, out.String(), "\a")
+	})
+	t.Run("logger sink writes a structured line", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		LoggerSink(NewLoggerWithClock(NewFakeClock(), out, LevelInfo, LogFormatText)).Alert(300, io.Discard)
+
+		assertResponseBody(t, out.String(), "time=2021-01-01T19:00:00Z level=info msg=\"blind changed\" amount=300\n")
+	})
+	t.Run("broadcaster sends JSON to every registered writer", func(t *testing.T) {
+		watcher, leaver := &bytes.Buffer{}, &bytes.Buffer{}
+		broadcaster := NewBroadcaster()
+		broadcaster.Add(watcher)
+		broadcaster.Add(leaver)
+		broadcaster.Remove(leaver)
+
+		broadcaster.Alert(500, io.Discard)
+
+		var got BlindAlertMessage
+		json.NewDecoder(watcher).Decode(&got)
+		if got != (BlindAlertMessage{Type: "blind", Amount: 500}) {
+			t.Errorf("got %+v broadcast", got)
+		}
+		if leaver.Len() != 0 {
+			t.Errorf("removed writer was sent %q", leaver.String())
+		}
+	})
+	t.Run("webhooks announce blind changes", func(t *testing.T) {
+		receiver := &spyWebhookReceiver{}
+		hooks := newTestWebhooks(t, receiver)
+
+		hooks.Alert(800, io.Discard)
+		hooks.Wait()
+
+		if len(receiver.bodies) != 1 || !strings.Contains(string(receiver.bodies[0]), EventBlindChanged) {
+			t.Errorf("expected a %s delivery, got %q", EventBlindChanged, receiver.bodies)
+		}
+	})
+}
+
+type spyWarningSink chan BlindWarning
+
+func (s spyWarningSink) Alert(amount int, to io.Writer) {}
+
+func (s spyWarningSink) Warn(warning BlindWarning, to io.Writer) {
+	s <- warning
+}
+
+type spyAlertSink chan int
+
+func newSpyAlertSink() spyAlertSink {
+	return make(spyAlertSink, 10)
+}
+
+func (s spyAlertSink) Alert(amount int, to io.Writer) {
+	s <- amount
+}
+
+func assertAlertReceived(t testing.TB, sink spyAlertSink, want int) {
+	t.Helper()
+	select {
+	case got := <-sink:
+		if got != want {
+			t.Errorf("got alert for %d, want %d", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for alert of %d", want)
+	}
+}
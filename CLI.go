@@ -2,37 +2,146 @@ package poker
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultBlindWarnings are how long before each blind increase players are
+// warned about it.
+var DefaultBlindWarnings = []time.Duration{time.Minute, 10 * time.Second}
+
+var defaultBlinds = []int{100, 200, 300, 400, 500, 600, 800, 1000, 2000, 4000, 8000}
+
 type TexasHoldem struct {
-	alerter BlindAlerter
-	store   PlayerStore
+	alerter  BlindAlerter
+	store    PlayerStore
+	clock    Clock
+	warnings []time.Duration
+	schedule []int
+	// levelEvery is how long each level lasts, or zero for five minutes
+	// plus one for every player.
+	levelEvery time.Duration
+
+	mu          sync.Mutex
+	to          io.Writer
+	players     []string
+	blinds      []int
+	levelLength time.Duration
+	level       int
+	levelEnds   time.Time
+	remaining   time.Duration
+	running     bool
+	paused      bool
+	logger      *Logger
+	// loggedStore is store logging under logger, made again when it changes.
+	loggedStore PlayerStore
+}
+
+func (t *TexasHoldem) Start(players []string, to io.Writer) {
+	t.mu.Lock()
+	store := t.loggedStore
+	t.mu.Unlock()
+
+	for _, player := range players {
+		store.RecordAttendance(player)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.to = to
+	t.players = players
+	t.blinds = t.schedule
+	t.levelLength = t.levelEvery
+	if t.levelLength == 0 {
+		t.levelLength = time.Duration(5+len(players)) * time.Minute
+	}
+	t.level = 0
+	t.remaining = t.levelLength
+	t.levelEnds = t.clock.Now().Add(t.remaining)
+	t.running = true
+	t.paused = false
+
+	t.logger.Info("blind clock started", "players", len(players), "level_length", t.levelLength)
+	t.scheduleLevels(true)
+}
+
+// SetLogger sets where the game logs what happens in it, which is nowhere
+// until it's called.
+func (t *TexasHoldem) SetLogger(logger *Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logger = logger
+	t.loggedStore = NewLoggingPlayerStore(t.store, logger)
+}
+
+// WarnBefore replaces DefaultBlindWarnings for this game. Calling it with no
+// durations leaves only the announcement of the next level at the start of
+// each level.
+func (t *TexasHoldem) WarnBefore(warnings ...time.Duration) {
+	t.warnings = warnings
+}
+
+// SetBlinds replaces the default blind schedule for games started from now
+// on. A levelLength of zero keeps the default of five minutes plus one for
+// every player.
+func (t *TexasHoldem) SetBlinds(blinds []int, levelLength time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.schedule = append([]int{}, blinds...)
+	t.levelEvery = levelLength
 }
 
-func (t *TexasHoldem) Start(numberOfPlayers int, to io.Writer) {
-	blindIncrement := time.Duration(5+numberOfPlayers) * time.Minute
-	blinds := []int{100, 200, 300, 400, 500, 600, 800, 1000, 2000, 4000, 8000}
-	blindTime := 0 * time.Second
-	for _, blind := range blinds {
-		t.alerter.ScheduledAlertAt(blindTime, blind, os.Stdout)
-		blindTime = blindTime + blindIncrement
+// Finish records a win for the winner and stops the blind clock, unless the
+// winner wasn't one of the players the game was started with.
+func (t *TexasHoldem) Finish(userInput string) error {
+	t.mu.Lock()
+	winner := seatedAs(extractWinner(userInput), t.players)
+	if winner == "" {
+		t.mu.Unlock()
+		return ErrWinnerNotSeated
 	}
+	t.running = false
+	t.cancelAlerts()
+	logger, store := t.logger, t.loggedStore
+	t.mu.Unlock()
+
+	logger.Info("game won", "winner", winner)
+	store.RecordWin(winner)
+	return nil
 }
 
-func (t *TexasHoldem) Finish(userInput string) {
-	t.store.RecordWin(extractWinner(userInput))
+// Abandon stops the blind clock without recording a win, so a game nobody
+// is playing any more isn't left running.
+func (t *TexasHoldem) Abandon() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return
+	}
+	t.running = false
+	t.cancelAlerts()
+	t.logger.Info("game abandoned")
 }
 
 func NewTexasHoldem(alerter BlindAlerter, store PlayerStore) *TexasHoldem {
+	return NewTexasHoldemWithClock(alerter, store, SystemClock)
+}
+
+func NewTexasHoldemWithClock(alerter BlindAlerter, store PlayerStore, clock Clock) *TexasHoldem {
 	return &TexasHoldem{
-		alerter: alerter,
-		store:   store,
+		alerter:     alerter,
+		store:       store,
+		clock:       clock,
+		warnings:    DefaultBlindWarnings,
+		schedule:    defaultBlinds,
+		logger:      DiscardLogger,
+		loggedStore: NewLoggingPlayerStore(store, DiscardLogger),
 	}
 }
 
@@ -50,30 +159,153 @@ func NewCLI(in io.Reader, out io.Writer, game Game) *CLI {
 	}
 }
 
-const PlayerPrompt = "Please enter the number of players: "
-const BadPlayerInputErrMsg = "Bad value received for number of players, please try again."
+const PlayerPrompt = "Please enter the names of the players, separated by commas: "
+const BadPlayerInputErrMsg = "Bad value received for the players' names, please try again."
+const BadPlayerCountErrMsg = "A game needs between 2 and 10 players, please try again."
+const BadWinnerInputErrMsg = "Please declare the winner as \"{Name} wins\"."
+const GameAbandonedMsg = "Game abandoned, no win was recorded."
 
+// GameHelp is what can be typed while a game is running.
+const GameHelp = `During a game:
+  pause, resume       stop and restart the blind clock
+  next, back          move to the next or previous blind level
+  add DURATION        make the current level longer, like add 5m
+  NAME wins           record NAME's win and end the game
+  abandon, quit       end the game without recording a win
+`
+
+const (
+	MinPlayers = 2
+	MaxPlayers = 10
+)
+
+// PlayPoker asks for the players and then runs a game until a winner is
+// declared, asking again whenever it gets input it can't use. It gives up
+// quietly if the input runs out.
 func (c *CLI) PlayPoker() {
-	fmt.Fprint(c.out, PlayerPrompt)
+	for {
+		fmt.Fprint(c.out, PlayerPrompt)
 
-	numberOfPlayersInput := c.readLine()
-	numberOfPlayers, err := strconv.Atoi(strings.Trim(numberOfPlayersInput, "\n"))
+		playersInput, ok := c.readLine()
+		if !ok {
+			return
+		}
 
-	if err != nil {
-		fmt.Fprint(c.out, BadPlayerInputErrMsg)
+		players, err := parsePlayers(playersInput)
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			continue
+		}
+
+		c.playGame(players)
 		return
 	}
-	c.game.Start(numberOfPlayers, c.out)
+}
+
+func (c *CLI) playGame(players []string) {
+	c.game.Start(players, c.out)
+
+	for {
+		winnerInput, ok := c.readLine()
+		if !ok {
+			return
+		}
+		switch strings.TrimSpace(winnerInput) {
+		case "help":
+			fmt.Fprint(c.out, GameHelp)
+			continue
+		case "abandon", "quit":
+			if abandonable, ok := c.game.(AbandonableGame); ok {
+				abandonable.Abandon()
+			}
+			fmt.Fprintln(c.out, GameAbandonedMsg)
+			return
+		}
+		if c.runClockCommand(winnerInput) {
+			continue
+		}
+
+		winner, err := parseWinner(winnerInput, players)
+		if err == nil {
+			err = c.game.Finish(winner)
+		}
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			continue
+		}
+		return
+	}
+}
 
-	winnerInput := c.readLine()
-	winner := extractWinner(winnerInput)
+// parsePlayers reads a comma separated list of the players' names.
+func parsePlayers(input string) ([]string, error) {
+	if !strings.Contains(input, ",") {
+		return nil, errors.New(BadPlayerInputErrMsg)
+	}
+
+	var names []string
+	for _, name := range strings.Split(input, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, errors.New(BadPlayerInputErrMsg)
+		}
+		if seatedAs(name, names) != "" {
+			return nil, fmt.Errorf("%s can only sit at the table once, please try again.", name)
+		}
+		names = append(names, name)
+	}
 
-	c.game.Finish(winner)
+	if len(names) < MinPlayers || len(names) > MaxPlayers {
+		return nil, errors.New(BadPlayerCountErrMsg)
+	}
+	return names, nil
 }
 
-func (c *CLI) readLine() string {
-	c.in.Scan()
-	return c.in.Text()
+// parseWinner reads a "{Name} wins" declaration. The winner has to be one of
+// the players, and is returned spelled as they were seated.
+func parseWinner(input string, names []string) (string, error) {
+	input = strings.TrimSpace(input)
+	if !strings.HasSuffix(input, " wins") {
+		return "", errors.New(BadWinnerInputErrMsg)
+	}
+
+	winner := strings.TrimSpace(extractWinner(input))
+	if winner == "" {
+		return "", errors.New(BadWinnerInputErrMsg)
+	}
+	if seated := seatedAs(winner, names); seated != "" {
+		return seated, nil
+	}
+	return "", fmt.Errorf("%s isn't at the table, the players are %s.", winner, strings.Join(names, ", "))
+}
+
+func seatedAs(name string, names []string) string {
+	for _, seated := range names {
+		if strings.EqualFold(seated, name) {
+			return seated
+		}
+	}
+	return ""
+}
+
+func (c *CLI) runClockCommand(line string) bool {
+	clock, ok := c.game.(BlindClock)
+	if !ok {
+		return false
+	}
+
+	handled, err := RunClockCommand(clock, line)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+	}
+	return handled
+}
+
+func (c *CLI) readLine() (string, bool) {
+	if !c.in.Scan() {
+		return "", false
+	}
+	return c.in.Text(), true
 }
 
 func extractWinner(userInput string) string {
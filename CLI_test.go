@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"reflect"
 	poker "server"
 	"strings"
 	"testing"
@@ -15,9 +16,12 @@ var dummyPlayerStore = &poker.StubPlayerStore{}
 var dummyStdIn = &bytes.Buffer{}
 var dummyStdOut = &bytes.Buffer{}
 
+var fivePlayers = []string{"Paul", "Rand", "Chris", "Cleo", "Whiskeyjack"}
+var sevenPlayers = append(fivePlayers[:5:5], "Tiest", "Pepper")
+
 func TestCLI(t *testing.T) {
 	t.Run("Paul's wins are recorded", func(t *testing.T) {
-		in := strings.NewReader("7\nPaul wins\n")
+		in := strings.NewReader(strings.Join(sevenPlayers, ", ") + "\nPaul wins\n")
 		playerstore := &poker.StubPlayerStore{}
 		dummyAlerter := &SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(dummyAlerter, playerstore)
@@ -26,7 +30,7 @@ func TestCLI(t *testing.T) {
 		poker.AssertPlayerWin(t, playerstore, "Paul")
 	})
 	t.Run("Rand's wins are recorded", func(t *testing.T) {
-		in := strings.NewReader("7\nRand wins\n")
+		in := strings.NewReader(strings.Join(sevenPlayers, ", ") + "\nRand wins\n")
 		playerstore := &poker.StubPlayerStore{}
 		dummyAlerter := &SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(dummyAlerter, playerstore)
@@ -35,7 +39,7 @@ func TestCLI(t *testing.T) {
 		poker.AssertPlayerWin(t, playerstore, "Rand")
 	})
 	t.Run("it schedules printing of blind values", func(t *testing.T) {
-		in := strings.NewReader("5\nChris wins\n")
+		in := strings.NewReader(strings.Join(fivePlayers, ", ") + "\nChris wins\n")
 		playerStore := &poker.StubPlayerStore{}
 		blindAlerter := &SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(blindAlerter, playerStore)
@@ -69,16 +73,31 @@ func TestCLI(t *testing.T) {
 			})
 		}
 	})
-	t.Run("it prompts the user to enter the number of players", func(t *testing.T) {
+	t.Run("passes clock commands to the game until a winner is declared", func(t *testing.T) {
+		in := strings.NewReader(strings.Join(sevenPlayers, ", ") + "\npause\nadd 5m\nresume\nPaul wins\n")
+		game := &poker.GameSpy{}
+
+		cli := poker.NewCLI(in, dummyStdOut, game)
+		cli.PlayPoker()
+
+		want := []string{"pause", "add 5m0s", "resume"}
+		if !reflect.DeepEqual(game.ClockCommands, want) {
+			t.Errorf("got clock commands %v, want %v", game.ClockCommands, want)
+		}
+		if game.FinishedWith != "Paul" {
+			t.Errorf("wanted Paul to win, got %q", game.FinishedWith)
+		}
+	})
+	t.Run("it prompts the user to enter the players", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		in := strings.NewReader("7\n")
+		in := strings.NewReader("Paul, Rand, Chris, Cleo, Whiskeyjack, Tiest, Pepper\n")
 
 		game := &poker.GameSpy{}
 		cli := poker.NewCLI(in, stdout, game)
 		cli.PlayPoker()
 
 		assertMessageSentToUser(t, stdout, poker.PlayerPrompt)
-		assertStartedWith(t, game.StartedWith, 7)
+		assertStartedWith(t, game.StartedWith, sevenPlayers)
 	})
 }
 func TestGame_Start(t *testing.T) {
@@ -86,7 +105,7 @@ func TestGame_Start(t *testing.T) {
 		blindAlerter := &SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
 
-		game.Start(5, io.Discard)
+		game.Start(fivePlayers, io.Discard)
 
 		cases := []scheduledAlert{
 			{0 * time.Second, 100},
@@ -108,7 +127,7 @@ func TestGame_Start(t *testing.T) {
 		blindAlerter := &SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
 
-		game.Start(7, io.Discard)
+		game.Start(sevenPlayers, io.Discard)
 
 		cases := []scheduledAlert{
 			{0 * time.Second, 100},
@@ -118,9 +137,49 @@ func TestGame_Start(t *testing.T) {
 		}
 		checkSchedulingCases(t, cases, blindAlerter)
 	})
-	t.Run("prints error when nonnumeric value is entered and does not start game", func(t *testing.T) {
+	t.Run("schedules a configured blind structure", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+		game.SetBlinds([]int{25, 50, 100}, 15*time.Minute)
+
+		game.Start(sevenPlayers, io.Discard)
+
+		cases := []scheduledAlert{
+			{0 * time.Second, 25},
+			{15 * time.Minute, 50},
+			{30 * time.Minute, 100},
+		}
+		checkSchedulingCases(t, cases, blindAlerter)
+		if len(blindAlerter.alerts) != len(cases) {
+			t.Errorf("got %d alerts, want %d", len(blindAlerter.alerts), len(cases))
+		}
+	})
+	t.Run("warns ahead of each blind increase", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+		game.WarnBefore(time.Minute)
+
+		game.Start(fivePlayers, io.Discard)
+
+		cases := []scheduledWarning{
+			{0 * time.Second, poker.BlindWarning{Amount: 200, In: 10 * time.Minute}},
+			{9 * time.Minute, poker.BlindWarning{Amount: 200, In: time.Minute}},
+			{10 * time.Minute, poker.BlindWarning{Amount: 300, In: 10 * time.Minute}},
+			{19 * time.Minute, poker.BlindWarning{Amount: 300, In: time.Minute}},
+		}
+
+		for i, c := range cases {
+			if len(blindAlerter.warnings) <= i {
+				t.Fatalf("warning %d was not scheduled %v", i, blindAlerter.warnings)
+			}
+			if blindAlerter.warnings[i] != c {
+				t.Errorf("got warning %+v, want %+v", blindAlerter.warnings[i], c)
+			}
+		}
+	})
+	t.Run("prints error when a number of players is entered and does not start game", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		in := strings.NewReader("Pies\n")
+		in := strings.NewReader("7\n")
 		game := &poker.GameSpy{}
 
 		cli := poker.NewCLI(in, stdout, game)
@@ -129,7 +188,191 @@ func TestGame_Start(t *testing.T) {
 			t.Errorf("Game should not have started")
 		}
 
-		assertMessageSentToUser(t, stdout, poker.PlayerPrompt, poker.BadPlayerInputErrMsg)
+		assertMessageSentToUser(t, stdout, poker.PlayerPrompt, poker.BadPlayerInputErrMsg, "\n", poker.PlayerPrompt)
+	})
+	t.Run("keeps asking until it gets a usable list of players", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		in := strings.NewReader("Pies\nPaul,\nPaul, Paul\nA, B, C, D, E, F, G, H, I, J, K\nPaul, Rand\n")
+		game := &poker.GameSpy{}
+
+		cli := poker.NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertMessageSentToUser(t, stdout,
+			poker.PlayerPrompt, poker.BadPlayerInputErrMsg, "\n",
+			poker.PlayerPrompt, poker.BadPlayerInputErrMsg, "\n",
+			poker.PlayerPrompt, "Paul can only sit at the table once, please try again.\n",
+			poker.PlayerPrompt, poker.BadPlayerCountErrMsg, "\n",
+			poker.PlayerPrompt,
+		)
+		assertStartedWith(t, game.StartedWith, []string{"Paul", "Rand"})
+	})
+	t.Run("seats named players and only accepts one of them as the winner", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		in := strings.NewReader("Paul, Rand, Chris\nPaul\nCleo wins\nrand wins\n")
+		game := &poker.GameSpy{}
+
+		cli := poker.NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertMessageSentToUser(t, stdout,
+			poker.PlayerPrompt,
+			poker.BadWinnerInputErrMsg, "\n",
+			"Cleo isn't at the table, the players are Paul, Rand, Chris.\n",
+		)
+		assertStartedWith(t, game.StartedWith, []string{"Paul", "Rand", "Chris"})
+		if game.FinishedWith != "Rand" {
+			t.Errorf("wanted Rand to win, got %q", game.FinishedWith)
+		}
+	})
+	t.Run("does not seat the same player twice", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		in := strings.NewReader("Paul, paul\n")
+		game := &poker.GameSpy{}
+
+		cli := poker.NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertMessageSentToUser(t, stdout, poker.PlayerPrompt, "paul can only sit at the table once, please try again.\n", poker.PlayerPrompt)
+		if game.StartCalled {
+			t.Errorf("Game should not have started")
+		}
+	})
+}
+
+func TestGame_Clock(t *testing.T) {
+	t.Run("skipping a level announces it and reschedules the rest", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+		game.Start(fivePlayers, io.Discard)
+		blindAlerter.alerts = nil
+
+		assertNoClockError(t, game.NextLevel())
+
+		checkSchedulingCases(t, []scheduledAlert{
+			{0 * time.Second, 200},
+			{10 * time.Minute, 300},
+			{20 * time.Minute, 400},
+		}, blindAlerter)
+		if blindAlerter.cancelled != 2 {
+			t.Errorf("expected pending alerts to be cancelled on start and skip, got %d cancellations", blindAlerter.cancelled)
+		}
+	})
+	t.Run("going back from the first level is an error", func(t *testing.T) {
+		game := poker.NewTexasHoldem(&SpyBlindAlerter{}, dummyPlayerStore)
+		game.Start(fivePlayers, io.Discard)
+
+		if err := game.PreviousLevel(); err != poker.ErrNoPreviousLevel {
+			t.Errorf("got %v, want %v", err, poker.ErrNoPreviousLevel)
+		}
+	})
+	t.Run("pausing cancels alerts and resuming reschedules them", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		clock := poker.NewFakeClock()
+		game := poker.NewTexasHoldemWithClock(blindAlerter, dummyPlayerStore, clock)
+		game.Start(fivePlayers, io.Discard)
+		clock.Advance(4 * time.Minute)
+
+		assertNoClockError(t, game.Pause())
+		if err := game.Pause(); err != poker.ErrClockPaused {
+			t.Errorf("got %v pausing twice, want %v", err, poker.ErrClockPaused)
+		}
+		assertNoClockError(t, game.AddTime(5*time.Minute))
+
+		blindAlerter.alerts = nil
+		assertNoClockError(t, game.Resume())
+
+		if len(blindAlerter.alerts) != 10 {
+			t.Fatalf("got %d alerts rescheduled, want 10", len(blindAlerter.alerts))
+		}
+		assertScheduledAlert(t, blindAlerter.alerts[0], scheduledAlert{11 * time.Minute, 200})
+	})
+	t.Run("clock commands need a running game", func(t *testing.T) {
+		game := poker.NewTexasHoldem(&SpyBlindAlerter{}, dummyPlayerStore)
+
+		if err := game.Pause(); err != poker.ErrGameNotRunning {
+			t.Errorf("got %v, want %v", err, poker.ErrGameNotRunning)
+		}
+	})
+}
+
+func TestGame_ClockEndToEnd(t *testing.T) {
+	clock := poker.NewFakeClock()
+	alerter := poker.NewScheduledAlerterWithClock(clock, poker.WriterSink)
+	game := poker.NewTexasHoldemWithClock(alerter, dummyPlayerStore, clock)
+	game.WarnBefore()
+	out := &bytes.Buffer{}
+
+	game.Start(fivePlayers, out)
+	clock.Advance(0)
+	assertAlertsWritten(t, out, "Blind is now 100\n", "Blinds go up to 200 in 10m0s\n")
+
+	clock.Advance(6 * time.Minute)
+	assertAlertsWritten(t, out)
+
+	assertNoClockError(t, game.Pause())
+	clock.Advance(time.Hour)
+	assertAlertsWritten(t, out, "Clock paused at blind 100 with 4m0s left in the level\n")
+
+	assertNoClockError(t, game.Resume())
+	clock.Advance(3 * time.Minute)
+	assertAlertsWritten(t, out,
+		"Clock running at blind 100 with 4m0s left in the level\n",
+		"Blinds go up to 200 in 4m0s\n",
+	)
+
+	clock.Advance(time.Minute)
+	assertAlertsWritten(t, out, "Blind is now 200\n", "Blinds go up to 300 in 10m0s\n")
+
+	assertNoClockError(t, game.NextLevel())
+	clock.Advance(0)
+	assertAlertsWritten(t, out,
+		"Clock running at blind 300 with 10m0s left in the level\n",
+		"Blind is now 300\n",
+		"Blinds go up to 400 in 10m0s\n",
+	)
+
+	game.Finish("Paul wins")
+	clock.Advance(time.Hour)
+	assertAlertsWritten(t, out)
+}
+
+func TestRunClockCommand(t *testing.T) {
+	game := &poker.GameSpy{}
+
+	for _, line := range []string{"pause", "next", "back", "add 90s", "Paul wins", "pause wins"} {
+		poker.RunClockCommand(game, line)
+	}
+	_, err := poker.RunClockCommand(game, "add soon")
+
+	want := []string{"pause", "next", "back", "add 1m30s"}
+	if !reflect.DeepEqual(game.ClockCommands, want) {
+		t.Errorf("got clock commands %v, want %v", game.ClockCommands, want)
+	}
+	if err == nil {
+		t.Error("expected an error for a bad duration but didn't get one")
+	}
+}
+
+func TestTexasHoldem_Players(t *testing.T) {
+	t.Run("records attendance for everyone at the table", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(&SpyBlindAlerter{}, store)
+
+		game.Start([]string{"Paul", "Rand"}, io.Discard)
+
+		poker.AssertAttendance(t, store, "Paul", "Rand")
+	})
+	t.Run("rejects a winner who wasn't at the table", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(&SpyBlindAlerter{}, store)
+		game.Start([]string{"Paul", "Rand"}, io.Discard)
+
+		if err := game.Finish("Cleo wins"); err != poker.ErrWinnerNotSeated {
+			t.Errorf("got %v, want %v", err, poker.ErrWinnerNotSeated)
+		}
+		assertNoClockError(t, game.Finish("paul wins"))
+		poker.AssertPlayerWin(t, store, "Paul")
 	})
 }
 
@@ -146,7 +389,14 @@ func TestGame_Finish(t *testing.T) {
 }
 
 type SpyBlindAlerter struct {
-	alerts []scheduledAlert
+	alerts    []scheduledAlert
+	warnings  []scheduledWarning
+	cancelled int
+}
+
+type scheduledWarning struct {
+	scheduledAt time.Duration
+	warning     poker.BlindWarning
 }
 
 type scheduledAlert struct {
@@ -162,6 +412,14 @@ func (s *SpyBlindAlerter) ScheduledAlertAt(duration time.Duration, amount int, t
 	s.alerts = append(s.alerts, scheduledAlert{duration, amount})
 }
 
+func (s *SpyBlindAlerter) ScheduledWarningAt(duration time.Duration, warning poker.BlindWarning, to io.Writer) {
+	s.warnings = append(s.warnings, scheduledWarning{duration, warning})
+}
+
+func (s *SpyBlindAlerter) CancelAlerts() {
+	s.cancelled++
+}
+
 func checkSchedulingCases(t *testing.T, cases []scheduledAlert, blindAlerter *SpyBlindAlerter) {
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("%d scheduled for %v", c.amount, c.scheduledAt), func(t *testing.T) {
@@ -186,9 +444,26 @@ func assertScheduledAlert(t testing.TB, got, want scheduledAlert) {
 	}
 }
 
-func assertStartedWith(t testing.TB, got, want int) {
+func assertStartedWith(t testing.TB, got, want []string) {
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wanted start with %v but got %v", want, got)
+	}
+}
+
+func assertAlertsWritten(t testing.TB, out *bytes.Buffer, alerts ...string) {
+	t.Helper()
+	got := out.String()
+	want := strings.Join(alerts, "")
 	if got != want {
-		t.Errorf("Wanted start with of %d but got %d", want, got)
+		t.Errorf("got alerts %q, want %q", got, want)
+	}
+	out.Reset()
+}
+
+func assertNoClockError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error from the clock but got %v", err)
 	}
 }
 
@@ -1,8 +1,51 @@
 package poker
 
-import "io"
+import (
+	"errors"
+	"io"
+	"time"
+)
 
 type Game interface {
-	Start(numberOfPlayers int, to io.Writer)
-	Finish(winner string)
+	Start(players []string, to io.Writer)
+	Finish(winner string) error
+}
+
+var ErrWinnerNotSeated = errors.New("the winner has to be one of the players at the table")
+
+// ErrGameInProgress is what a WebSocket client is told when it tries to
+// start a game while another is being played, as there is only one table.
+var ErrGameInProgress = errors.New("a game is already being played, please wait for it to finish")
+
+// BlindClock is implemented by games whose blind schedule can be adjusted
+// while they are being played.
+type BlindClock interface {
+	Pause() error
+	Resume() error
+	NextLevel() error
+	PreviousLevel() error
+	AddTime(d time.Duration) error
+}
+
+// GameState is where a game in progress had got to, enough to pick it back
+// up by hand after the server stops.
+type GameState struct {
+	Players   []string
+	Level     int
+	Blind     int
+	Remaining time.Duration
+	Paused    bool
+	Saved     time.Time
+}
+
+// StatefulGame is implemented by games that can say where they have got to.
+// State reports false when no game is in progress.
+type StatefulGame interface {
+	State() (GameState, bool)
+}
+
+// AbandonableGame is implemented by games that can be given up without a
+// winner, for when everyone playing has gone.
+type AbandonableGame interface {
+	Abandon()
 }
@@ -0,0 +1,147 @@
+package poker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// embeddedAssets are the page templates and everything under static/, built
+// into the binary so the server runs from any directory.
+//
+//go:embed *.html static
+var embeddedAssets embed.FS
+
+const (
+	staticDir = "static"
+	// versionedCacheControl is for asset URLs with the file's hash in them,
+	// from the static template function. A new version of the file has a new
+	// URL, so the old one can be kept for good. Any other URL is revalidated
+	// by its ETag every time.
+	versionedCacheControl   = "public, max-age=31536000, immutable"
+	unversionedCacheControl = "no-cache"
+)
+
+// WithAssetDir serves the page templates and static/ from dir instead of
+// the copies built in, reading them afresh on every request so changes show up on a
+// reload. It is meant for working on the page.
+func WithAssetDir(dir string) ServerOption {
+	return func(p *PlayerServer) {
+		p.assets = os.DirFS(dir)
+		p.reloadAssets = true
+	}
+}
+
+// loadTemplates parses the pages, the game page from the file given to
+// WithTemplate if there was one and everything else from the assets.
+func (p *PlayerServer) loadTemplates() (*template.Template, error) {
+	funcs := template.FuncMap{"static": p.staticURL}
+	tmpl, err := template.New("").Funcs(pageFuncs).Funcs(funcs).ParseFS(p.assets, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("problem opening the page templates, %v", err)
+	}
+
+	if p.templatePath != "" {
+		page, err := os.ReadFile(p.templatePath)
+		if err == nil {
+			_, err = tmpl.New(htmlTemplatePath).Parse(string(page))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("problem opening %s %v", p.templatePath, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// pageTemplates are the templates to render pages from, parsed again each
+// time when the assets are being reloaded.
+func (p *PlayerServer) pageTemplates() (*template.Template, error) {
+	if p.reloadAssets {
+		return p.loadTemplates()
+	}
+	return p.templates, nil
+}
+
+// hashAssets tags every file under static/, for the ETags and versioned URLs
+// of assets that won't change while the server runs.
+func hashAssets(assets fs.FS) (map[string]string, error) {
+	tags := map[string]string{}
+	err := fs.WalkDir(assets, staticDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(assets, name)
+		if err != nil {
+			return err
+		}
+		tags[name] = assetTag(data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("problem reading the static assets, %v", err)
+	}
+	return tags, nil
+}
+
+func assetTag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// staticURL is the URL of name under static/ with its tag in it, so that
+// the URL changes whenever the file does.
+func (p *PlayerServer) staticURL(name string) (string, error) {
+	name = path.Join(staticDir, name)
+	tag, ok := p.assetTags[name]
+	if !ok {
+		data, err := fs.ReadFile(p.assets, name)
+		if err != nil {
+			return "", fmt.Errorf("problem finding asset %s, %v", name, err)
+		}
+		tag = assetTag(data)
+	}
+	return "/" + name + "?v=" + tag, nil
+}
+
+// staticHandler serves the files under static/. Asked for by a URL with the
+// file's current tag in it they can be cached for good, otherwise they are
+// revalidated by ETag each time.
+func (p *PlayerServer) staticHandler(w http.ResponseWriter, r *http.Request) {
+	name := path.Join(staticDir, strings.TrimPrefix(r.URL.Path, "/static/"))
+	if !fs.ValidPath(name) || name == staticDir {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := fs.ReadFile(p.assets, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	tag, ok := p.assetTags[name]
+	if !ok {
+		tag = assetTag(data)
+	}
+	w.Header().Set("etag", `"`+tag+`"`)
+	if !p.reloadAssets && r.URL.Query().Get("v") == tag {
+		w.Header().Set("cache-control", versionedCacheControl)
+	} else {
+		w.Header().Set("cache-control", unversionedCacheControl)
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("content-type", contentType)
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
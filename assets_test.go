@@ -0,0 +1,110 @@
+package poker
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStatic(t *testing.T) {
+	t.Run("serves built in assets for good from the versioned URLs on the page", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame)
+
+		script := scriptURL(t, getStatic(t, server, "/game", "").Body.String())
+		response := getStatic(t, server, script, "")
+
+		assertStatus(t, response, http.StatusOK)
+		if got := response.Header().Get("content-type"); !strings.Contains(got, "javascript") {
+			t.Errorf("got content-type %q, want javascript", got)
+		}
+		if got := response.Header().Get("cache-control"); got != versionedCacheControl {
+			t.Errorf("got cache-control %q, want %q", got, versionedCacheControl)
+		}
+		if !strings.Contains(response.Body.String(), "WebSocket") {
+			t.Errorf("got %q, want the game script", response.Body.String())
+		}
+	})
+	t.Run("has unversioned URLs revalidated by ETag", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame)
+
+		for _, path := range []string{"/static/game.js", "/static/game.js?v=stale"} {
+			response := getStatic(t, server, path, "")
+			if got := response.Header().Get("cache-control"); got != unversionedCacheControl {
+				t.Errorf("got cache-control %q for %s, want %q", got, path, unversionedCacheControl)
+			}
+
+			etag := response.Header().Get("etag")
+			if etag == "" {
+				t.Fatal("expected an etag")
+			}
+			assertStatus(t, getStatic(t, server, path, etag), http.StatusNotModified)
+		}
+	})
+	t.Run("only serves what is under static", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame)
+
+		for _, path := range []string{"/static/missing.css", "/static/", "/static/game.html"} {
+			assertStatus(t, getStatic(t, server, path, ""), http.StatusNotFound)
+		}
+	})
+	t.Run("reloads the page and assets from an asset directory", func(t *testing.T) {
+		dir := t.TempDir()
+		writeAsset(t, dir, "game.html", "first draft")
+		writeAsset(t, dir, "static/game.js", "let first")
+		server, err := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithAssetDir(dir))
+		assertNoError(t, err)
+
+		assertResponseBody(t, getStatic(t, server, "/game", "").Body.String(), "first draft")
+
+		writeAsset(t, dir, "game.html", `second draft <script src="{{static "game.js"}}"></script>`)
+		first := scriptURL(t, getStatic(t, server, "/game", "").Body.String())
+
+		writeAsset(t, dir, "static/game.js", "let second")
+		second := scriptURL(t, getStatic(t, server, "/game", "").Body.String())
+		if first == second {
+			t.Errorf("got the same URL %s for both versions of the script", first)
+		}
+
+		response := getStatic(t, server, second, "")
+		assertResponseBody(t, response.Body.String(), "let second")
+		if got := response.Header().Get("cache-control"); got != unversionedCacheControl {
+			t.Errorf("got cache-control %q, want %q", got, unversionedCacheControl)
+		}
+	})
+}
+
+func getStatic(t testing.TB, server http.Handler, path, etag string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if etag != "" {
+		request.Header.Set("if-none-match", etag)
+	}
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func scriptURL(t testing.TB, page string) string {
+	t.Helper()
+	match := regexp.MustCompile(`<script src="([^"]+)"`).FindStringSubmatch(page)
+	if match == nil {
+		t.Fatalf("no script on the page %q", page)
+	}
+	return html.UnescapeString(match[1])
+}
+
+func writeAsset(t testing.TB, dir, name, contents string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
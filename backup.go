@@ -0,0 +1,279 @@
+package poker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupPrefix     = "game-"
+	backupSuffix     = ".backup.json"
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+var ErrDatabaseExists = errors.New("refusing to restore over a database that already has data in it")
+
+// StoreBackup is a consistent copy of a store: its league along with the
+// event log that league was replayed from, and the events compacted out of
+// that log into its archive.
+type StoreBackup struct {
+	Taken    time.Time
+	League   League
+	Events   []Event
+	Archived []Event `json:",omitempty"`
+}
+
+// BackupSource is implemented by stores that can write out a StoreBackup of
+// themselves.
+type BackupSource interface {
+	Backup(w io.Writer) error
+}
+
+// Backup writes a StoreBackup of the store as JSON, holding off other
+// changes while it does.
+func (f *FileSystemPlayerStore) Backup(w io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	backup := StoreBackup{
+		Taken:    time.Now().UTC(),
+		League:   append(League{}, f.league...),
+		Events:   f.events.Events(),
+		Archived: f.events.Archived(),
+	}
+	if err := json.NewEncoder(w).Encode(backup); err != nil {
+		return fmt.Errorf("problem writing backup, %v", err)
+	}
+	return nil
+}
+
+// WriteBackup saves a backup of store into dir, named after when it was
+// taken, then deletes all but the newest keep backups there. Zero keeps
+// them all.
+func WriteBackup(store BackupSource, dir string, keep int, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("problem creating backup directory %s, %v", dir, err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+now.UTC().Format(backupTimeFormat)+backupSuffix)
+	temp, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", fmt.Errorf("problem creating backup in %s, %v", dir, err)
+	}
+	defer os.Remove(temp.Name())
+
+	err = store.Backup(temp)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return "", fmt.Errorf("problem saving backup %s, %v", path, err)
+	}
+
+	return path, pruneBackups(dir, keep)
+}
+
+// ListBackups returns the backups in dir, oldest first.
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("problem listing backups in %s, %v", dir, err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func pruneBackups(dir string, keep int) error {
+	backups, err := ListBackups(dir)
+	if err != nil || keep <= 0 || len(backups) <= keep {
+		return err
+	}
+
+	for _, old := range backups[:len(backups)-keep] {
+		if err := os.Remove(old); err != nil {
+			return fmt.Errorf("problem removing old backup %s, %v", old, err)
+		}
+	}
+	return nil
+}
+
+// ScheduleBackups writes a backup of store into dir every interval until
+// the returned stop function is called, logging any that fail.
+func ScheduleBackups(clock Clock, interval time.Duration, store BackupSource, dir string, keep int) (stop func()) {
+	var mu sync.Mutex
+	var timer Timer
+	stopped := false
+	var next func()
+
+	next = func() {
+		if path, err := WriteBackup(store, dir, keep, clock.Now()); err != nil {
+			DefaultLogger.Error("problem backing up the player store", "err", err)
+		} else {
+			DefaultLogger.Info("backed up the player store", "path", path)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			timer = clock.AfterFunc(interval, next)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	timer = clock.AfterFunc(interval, next)
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		timer.Stop()
+	}
+}
+
+// RestoreBackup rebuilds the database at dbPath, and its event log and
+// archive, from the backup at backupPath. dbPath must not already hold any players. The
+// restored store is opened and checked against the backup's league before
+// being kept.
+func RestoreBackup(backupPath, dbPath string) error {
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("problem opening backup %s, %v", backupPath, err)
+	}
+	defer file.Close()
+
+	var backup StoreBackup
+	if err := json.NewDecoder(file).Decode(&backup); err != nil {
+		return fmt.Errorf("problem reading backup %s, %v", backupPath, err)
+	}
+
+	if err := checkFreshDatabase(dbPath); err != nil {
+		return err
+	}
+
+	if err := writeRestoredFiles(backup, dbPath); err != nil {
+		return err
+	}
+
+	if err := verifyRestore(backup, dbPath); err != nil {
+		os.Remove(dbPath)
+		os.Remove(dbPath + eventLogSuffix)
+		os.Remove(dbPath + eventArchiveSuffix)
+		return err
+	}
+	return nil
+}
+
+func checkFreshDatabase(dbPath string) error {
+	// A log or archive with anything in it never reads as an empty league.
+	for _, path := range []string{dbPath, dbPath + eventLogSuffix, dbPath + eventArchiveSuffix} {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("problem checking %s, %v", path, err)
+		}
+		if info.Size() > 0 && !isEmptyLeagueFile(path) {
+			return fmt.Errorf("%s, %w", path, ErrDatabaseExists)
+		}
+	}
+	return nil
+}
+
+func isEmptyLeagueFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	league, err := NewLeague(file)
+	return err == nil && len(league) == 0
+}
+
+func writeRestoredFiles(backup StoreBackup, dbPath string) error {
+	league := backup.League
+	if league == nil {
+		league = League{}
+	}
+	data, err := json.Marshal(LeagueFile{Version: LeagueSchemaVersion, League: league})
+	if err != nil {
+		return fmt.Errorf("problem encoding restored league, %v", err)
+	}
+	if err := os.WriteFile(dbPath, data, 0666); err != nil {
+		return fmt.Errorf("problem writing %s, %v", dbPath, err)
+	}
+
+	if err := writeEventsFile(dbPath+eventLogSuffix, backup.Events); err != nil {
+		return err
+	}
+	return writeEventsFile(dbPath+eventArchiveSuffix, backup.Archived)
+}
+
+func writeEventsFile(path string, events []Event) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("problem creating %s, %v", path, err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("problem writing event %d to %s, %v", event.Seq, path, err)
+		}
+	}
+	return file.Sync()
+}
+
+func verifyRestore(backup StoreBackup, dbPath string) error {
+	store, closeFunc, err := FileSystemStoreFromFile(dbPath)
+	if err != nil {
+		return fmt.Errorf("restored database does not open, %v", err)
+	}
+	defer closeFunc()
+
+	got := sortedByName(store.GetLeague())
+	want := sortedByName(backup.League)
+	if len(got) != len(want) {
+		return fmt.Errorf("restored database has %d players, the backup has %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return fmt.Errorf("restored database has %+v, the backup has %+v", got[i], want[i])
+		}
+	}
+	return nil
+}
+
+func sortedByName(league League) League {
+	sorted := append(League{}, league...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
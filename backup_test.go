@@ -0,0 +1,115 @@
+package poker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackup(t *testing.T) {
+	t.Run("restores a backup into a fresh database", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		store.RecordWin("Paul")
+		store.RecordWin("Rand")
+
+		path, err := WriteBackup(store, dir, 0, time.Now())
+		assertNoError(t, err)
+
+		restored := filepath.Join(dir, "restored.db.json")
+		assertNoError(t, RestoreBackup(path, restored))
+
+		reopened, closeFunc, err := FileSystemStoreFromFile(restored)
+		assertNoError(t, err)
+		defer closeFunc()
+		assertPlayerScore(t, reopened.GetPlayerScore("Rand"), 1)
+
+		undone, err := reopened.UndoLastWin()
+		if err != nil || undone != "Rand" {
+			t.Errorf("got %q, %v from undo, the restored log should remember Rand's win", undone, err)
+		}
+	})
+	t.Run("restores the history compacted into the archive", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		store.snapshotEvery = 3
+		store.compactAfter = 4
+		for i := 0; i < 4; i++ {
+			store.RecordAttendance("Paul")
+			store.RecordAttendance("Rand")
+			store.RecordWin("Paul")
+		}
+
+		path, err := WriteBackup(store, dir, 0, time.Now())
+		assertNoError(t, err)
+
+		restored := filepath.Join(dir, "restored.db.json")
+		assertNoError(t, RestoreBackup(path, restored))
+
+		reopened, closeFunc, err := FileSystemStoreFromFile(restored)
+		assertNoError(t, err)
+		defer closeFunc()
+		if games := GameHistory(reopened.Events()); len(games) != 4 {
+			t.Errorf("got %d games in the restored history, want all 4", len(games))
+		}
+	})
+	t.Run("won't restore over a leftover archive", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		store.RecordWin("Paul")
+
+		path, err := WriteBackup(store, dir, 0, time.Now())
+		assertNoError(t, err)
+
+		restored := filepath.Join(dir, "restored.db.json")
+		assertNoError(t, os.WriteFile(restored+eventArchiveSuffix, []byte(`{"Seq": 1, "Type": "WinRecorded", "Player": "Cleo"}`+"\n"), 0666))
+
+		err = RestoreBackup(path, restored)
+		if !errors.Is(err, ErrDatabaseExists) {
+			t.Errorf("got %v, want %v", err, ErrDatabaseExists)
+		}
+	})
+	t.Run("won't restore over a database with players in it", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		store.RecordWin("Paul")
+
+		path, err := WriteBackup(store, dir, 0, time.Now())
+		assertNoError(t, err)
+
+		err = RestoreBackup(path, filepath.Join(dir, "game.db.json"))
+		if !errors.Is(err, ErrDatabaseExists) {
+			t.Errorf("got %v, want %v", err, ErrDatabaseExists)
+		}
+	})
+	t.Run("keeps the newest backups on a schedule", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		clock := NewFakeClock()
+
+		stop := ScheduleBackups(clock, time.Hour, store, filepath.Join(dir, "backups"), 2)
+		clock.Advance(3 * time.Hour)
+		stop()
+		clock.Advance(time.Hour)
+
+		backups, err := ListBackups(filepath.Join(dir, "backups"))
+		assertNoError(t, err)
+		if len(backups) != 2 {
+			t.Fatalf("got backups %v, want the last 2", backups)
+		}
+		want := backupPrefix + clock.Now().Add(-time.Hour).Format(backupTimeFormat) + backupSuffix
+		if filepath.Base(backups[1]) != want {
+			t.Errorf("got newest backup %s, want %s", backups[1], want)
+		}
+	})
+}
+
+func newBackedUpStore(t *testing.T, dir string) *FileSystemPlayerStore {
+	t.Helper()
+	store, closeFunc, err := FileSystemStoreFromFile(filepath.Join(dir, "game.db.json"))
+	assertNoError(t, err)
+	t.Cleanup(closeFunc)
+	return store
+}
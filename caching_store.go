@@ -0,0 +1,136 @@
+package poker
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheStats counts how often a CachingPlayerStore answered from its cache.
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// HitRate is the fraction of reads answered from the cache.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CachingPlayerStore remembers the league and scores it reads from the store
+// behind it for up to ttl, forgetting them as soon as anything changes.
+type CachingPlayerStore struct {
+	PlayerStore
+	wrappedStore
+	clock Clock
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	league   League
+	leagueAt time.Time
+	scores   map[string]cachedScore
+	stats    CacheStats
+}
+
+type cachedScore struct {
+	wins int
+	at   time.Time
+}
+
+func NewCachingPlayerStore(store PlayerStore, ttl time.Duration) *CachingPlayerStore {
+	return NewCachingPlayerStoreWithClock(SystemClock, store, ttl)
+}
+
+func NewCachingPlayerStoreWithClock(clock Clock, store PlayerStore, ttl time.Duration) *CachingPlayerStore {
+	return &CachingPlayerStore{
+		PlayerStore:  store,
+		wrappedStore: wrappedStore{store},
+		clock:        clock,
+		ttl:          ttl,
+		scores:       map[string]cachedScore{},
+	}
+}
+
+func (c *CachingPlayerStore) GetLeague() League {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.league != nil && c.fresh(c.leagueAt) {
+		c.stats.Hits++
+		return append(League{}, c.league...)
+	}
+
+	c.stats.Misses++
+	c.league = append(League{}, c.PlayerStore.GetLeague()...)
+	c.leagueAt = c.clock.Now()
+	return append(League{}, c.league...)
+}
+
+func (c *CachingPlayerStore) GetPlayerScore(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.scores[name]; ok && c.fresh(cached.at) {
+		c.stats.Hits++
+		return cached.wins
+	}
+
+	c.stats.Misses++
+	wins := c.PlayerStore.GetPlayerScore(name)
+	// Only players with wins are remembered, so asking after names nobody
+	// has can't grow the cache without end.
+	if wins > 0 {
+		c.scores[name] = cachedScore{wins: wins, at: c.clock.Now()}
+	}
+	return wins
+}
+
+func (c *CachingPlayerStore) RecordWin(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.PlayerStore.RecordWin(name)
+	c.invalidate()
+}
+
+func (c *CachingPlayerStore) RecordAttendance(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.PlayerStore.RecordAttendance(name)
+	c.invalidate()
+}
+
+func (c *CachingPlayerStore) UndoLastWin() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.invalidate()
+	return c.PlayerStore.UndoLastWin()
+}
+
+func (c *CachingPlayerStore) ImportPlayers(players []Player) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.invalidate()
+	return ImportPlayers(c.PlayerStore, players)
+}
+
+// Stats reports the cache's hits and misses so far.
+func (c *CachingPlayerStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *CachingPlayerStore) fresh(at time.Time) bool {
+	return c.clock.Now().Sub(at) < c.ttl
+}
+
+func (c *CachingPlayerStore) invalidate() {
+	c.league = nil
+	c.scores = map[string]cachedScore{}
+}
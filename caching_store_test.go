@@ -0,0 +1,67 @@
+package poker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type countingPlayerStore struct {
+	PlayerStore
+	leagueReads int
+}
+
+func (c *countingPlayerStore) GetLeague() League {
+	c.leagueReads++
+	return c.PlayerStore.GetLeague()
+}
+
+func TestCachingPlayerStore(t *testing.T) {
+	t.Run("answers repeat reads from the cache until the ttl runs out", func(t *testing.T) {
+		clock := NewFakeClock()
+		behind := &countingPlayerStore{PlayerStore: NewInMemoryPlayerStore()}
+		store := NewCachingPlayerStoreWithClock(clock, behind, time.Minute)
+
+		store.GetLeague()
+		store.GetLeague()
+		clock.Advance(time.Minute)
+		store.GetLeague()
+
+		if behind.leagueReads != 2 {
+			t.Errorf("read the league from the store behind %d times, want 2", behind.leagueReads)
+		}
+		if stats := store.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+			t.Errorf("got stats %+v", stats)
+		}
+	})
+	t.Run("forgets what it cached when a win is recorded", func(t *testing.T) {
+		store := NewCachingPlayerStoreWithClock(NewFakeClock(), NewInMemoryPlayerStore(), time.Hour)
+
+		assertPlayerScore(t, store.GetPlayerScore("Paul"), 0)
+		store.GetLeague()
+		store.RecordWin("Paul")
+
+		assertPlayerScore(t, store.GetPlayerScore("Paul"), 1)
+		assertLeague(t, store.GetLeague(), []Player{{Name: "Paul", Wins: 1}})
+	})
+	t.Run("doesn't remember scores for players nobody has heard of", func(t *testing.T) {
+		behind := NewInMemoryPlayerStore()
+		behind.RecordWin("Paul")
+		store := NewCachingPlayerStoreWithClock(NewFakeClock(), behind, time.Hour)
+
+		store.GetPlayerScore("Paul")
+		for i := 0; i < 100; i++ {
+			store.GetPlayerScore(fmt.Sprintf("stranger-%d", i))
+		}
+
+		if len(store.scores) != 1 {
+			t.Errorf("cached %d scores, want only Paul's", len(store.scores))
+		}
+	})
+}
+
+func TestCacheStats(t *testing.T) {
+	if got := (CacheStats{Hits: 3, Misses: 1}).HitRate(); got != 0.75 {
+		t.Errorf("got hit rate %v, want 0.75", got)
+	}
+}
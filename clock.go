@@ -0,0 +1,27 @@
+package poker
+
+import "time"
+
+// Clock is where the game and its alerters get the time from, so tests can
+// control it.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// SystemClock tells the real time.
+var SystemClock Clock = systemClock{}
@@ -1,24 +1,245 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	poker "server"
+	"strings"
+	"time"
 )
 
-const dbFileName = "game.db.json"
+const usage = `Usage:
+  cli [flags] [command]      flags set up every command, see cli -h
+  cli                        play games at the interactive prompt
+  cli export [flags]         write the league out as CSV or JSON
+  cli import [flags] FILE    add the results in a CSV or JSON file to the league
+  cli backup [flags]         save a backup of the player database
+  cli restore [flags] FILE   rebuild an empty player database from a backup
+  cli check [flags]          look for problems in the player database
+  cli repair [flags]         rebuild a corrupt player database from what can be saved
+  cli config                 print the configuration the flags, environment and config file add up to
+`
 
 func main() {
-	store, closeFunc, err := poker.FileSystemStoreFromFile(dbFileName)
+	config, args, err := poker.LoadConfig("cli", os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage)
+		return
+	}
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) > 1 {
+			fmt.Fprint(os.Stderr, usage)
+			log.Fatalf("config takes no arguments, got %q", strings.Join(args[1:], " "))
+		}
+		poker.WriteConfig(os.Stdout, config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	poker.DefaultLogger = config.Logger(os.Stderr)
+
+	// These work on the files directly, as they may not open as a store.
+	if len(args) > 0 {
+		if command, ok := fileCommands[args[0]]; ok {
+			if err := command(config, args[1:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	store, closeFunc, err := poker.FileSystemStoreFromFile(config.Store.Path)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer closeFunc()
 
+	if len(args) > 0 {
+		if err := runSubcommand(store, config, args[0], args[1:]); err != nil {
+			closeFunc()
+			log.Fatal(err)
+		}
+		return
+	}
+
 	fmt.Println("Let's play poker")
-	fmt.Println("Type {Name} wins to record a win")
-	game := poker.NewGame(poker.BlindAlerterFunc(poker.StdOutAlerter), store)
-	cli := poker.NewCLI(os.Stdin, os.Stdout, game)
-	cli.PlayPoker()
+	fmt.Println("Type new-game to seat the players, {Name} wins to record a win and help for everything else")
+	game := poker.NewTexasHoldem(poker.NewScheduledAlerter(poker.WriterSink, poker.BellSink), store)
+	game.SetBlinds(config.Blinds.Amounts, time.Duration(config.Blinds.LevelLength))
+	game.WarnBefore(config.Blinds.WarnBefore()...)
+	shell := poker.NewShell(os.Stdin, os.Stdout, game, store)
+	shell.Run()
+}
+
+var fileCommands = map[string]func(config poker.Config, args []string) error{
+	"restore": restore,
+	"check":   check,
+	"repair":  repair,
+}
+
+func runSubcommand(store *poker.FileSystemPlayerStore, config poker.Config, name string, args []string) error {
+	switch name {
+	case "export":
+		return export(store, args)
+	case "import":
+		return importFile(store, args)
+	case "backup":
+		return backup(store, config, args)
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func export(store poker.PlayerStore, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", poker.FormatCSV, "csv or json")
+	history := flags.Bool("history", false, "write every recorded event as CSV instead of the league")
+	output := flags.String("o", "", "file to write to instead of standard output")
+	flags.Parse(args)
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("problem creating %s, %v", *output, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if *history {
+		return poker.ExportHistory(out, store)
+	}
+	return poker.ExportLeague(out, store, *format)
+}
+
+func importFile(store poker.PlayerStore, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "csv or json, worked out from the file's extension if not given")
+	dryRun := flags.Bool("dry-run", false, "check the file and show what would be imported without changing the league")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("import needs exactly one file")
+	}
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("problem opening %s, %v", path, err)
+	}
+	defer file.Close()
+
+	report, err := poker.ImportLeague(store, file, *format, *dryRun)
+	for _, problem := range report.Problems {
+		fmt.Printf("row %d: %s\n", problem.Row, problem.Reason)
+	}
+	if err != nil {
+		return err
+	}
+
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s results for %d players.\n", verb, len(report.Players))
+	return nil
+}
+
+func backup(store poker.BackupSource, config poker.Config, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := flags.String("dir", config.Backup.Dir, "directory to save backups in")
+	keep := flags.Int("keep", config.Backup.Keep, "how many backups to keep, 0 for all of them")
+	flags.Parse(args)
+
+	path, err := poker.WriteBackup(store, *dir, *keep, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up to %s.\n", path)
+	return nil
+}
+
+func restore(config poker.Config, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	db := flags.String("db", config.Store.Path, "player database to restore into, which must be empty")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("restore needs exactly one backup file")
+	}
+
+	if err := poker.RestoreBackup(flags.Arg(0), *db); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s and checked it matches.\n", *db, flags.Arg(0))
+	return nil
+}
+
+func check(config poker.Config, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	db := flags.String("db", config.Store.Path, "player database to check")
+	flags.Parse(args)
+
+	data, err := os.ReadFile(*db)
+	if err != nil {
+		return fmt.Errorf("problem reading %s, %v", *db, err)
+	}
+
+	problems := poker.ValidateLeague(data)
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", *db, problem)
+	}
+
+	if logFile, err := os.Open(*db + ".log"); err == nil {
+		defer logFile.Close()
+		if _, err := poker.NewEventLog(logFile); err != nil {
+			fmt.Println(err)
+			problems = append(problems, poker.LeagueProblem{Reason: err.Error()})
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems, cli repair can rebuild what it can", len(problems))
+	}
+	fmt.Printf("%s looks fine.\n", *db)
+	return nil
+}
+
+func repair(config poker.Config, args []string) error {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	db := flags.String("db", config.Store.Path, "player database to repair")
+	flags.Parse(args)
+
+	report, err := poker.RepairLeagueFile(*db, time.Now())
+	for _, problem := range report.Skipped {
+		fmt.Printf("skipped %s\n", problem)
+	}
+	for _, path := range report.Quarantined {
+		fmt.Printf("moved the original to %s\n", path)
+	}
+	if err != nil {
+		return err
+	}
+
+	from := "the records that could be read"
+	if report.FromLog {
+		from = "its event log"
+	}
+	fmt.Printf("Rebuilt %s with %d players from %s.\n", *db, len(report.League), from)
+	return nil
 }
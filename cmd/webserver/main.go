@@ -1,30 +1,265 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	poker "server"
+	"strings"
+	"syscall"
+	"time"
 )
 
-const dbFileName = "game.db.json"
+const (
+	gameStateFileName      = "game.state.json"
+	selfSignedCertFileName = "tls.cert.pem"
+	selfSignedKeyFileName  = "tls.key.pem"
+)
+
+const usage = `Usage:
+  webserver [flags]          serve the league and games
+  webserver [flags] config   print the configuration the flags, environment and config file add up to
+`
 
 func main() {
-	db, err := os.OpenFile(dbFileName, os.O_RDWR|os.O_CREATE, 0666)
+	config, args, err := poker.LoadConfig("webserver", os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage)
+		return
+	}
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) > 1 {
+			fmt.Fprint(os.Stderr, usage)
+			log.Fatalf("config takes no arguments, got %q", strings.Join(args[1:], " "))
+		}
+		poker.WriteConfig(os.Stdout, config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 {
+		fmt.Fprint(os.Stderr, usage)
+		log.Fatalf("unknown command %q", strings.Join(args, " "))
+	}
 	if err != nil {
-		log.Fatalf("problem opening file: %s, %v", dbFileName, err)
+		log.Fatal(err)
 	}
-	store, err := poker.NewFileSystemPlayerStore(db)
 
-	if err != nil {
-		log.Fatalf("Error creating file system player store, %v", err)
+	logger := config.Logger(os.Stderr)
+	poker.DefaultLogger = logger
+
+	// closers are run in reverse order once the server has stopped, so the
+	// store is flushed before its files are closed.
+	var closers []func()
+
+	var store poker.PlayerStore
+	var backups poker.BackupSource
+	var dataDir string
+	if config.Store.Backend == poker.StoreBackendMemory {
+		store = poker.NewInMemoryPlayerStore()
+	} else {
+		fileStore, closeFunc := openFileStore(config.Store.Path, config.Backup)
+		closers = append(closers, closeFunc)
+		store = fileStore
+		backups = fileStore
+		dataDir = filepath.Dir(config.Store.Path)
+		reportInterruptedGame(logger, filepath.Join(dataDir, gameStateFileName))
+	}
+
+	if writeBehind := time.Duration(config.Store.WriteBehind); writeBehind > 0 {
+		batched := poker.NewWriteBehindPlayerStore(store, writeBehind)
+		closers = append(closers, batched.Close)
+		store = batched
+		if backups != nil {
+			// Back up through the batches so the changes still waiting to
+			// be written are included.
+			backups = batched
+		}
+	}
+
+	if every := time.Duration(config.Backup.Every); every > 0 && backups != nil {
+		closers = append(closers, poker.ScheduleBackups(poker.SystemClock, every, backups, config.Backup.Dir, config.Backup.Keep))
 	}
-	game := poker.NewTexasHoldem(poker.BlindAlerterFunc(poker.Alerter), store)
-	server, err := poker.NewPlayerServer(store, game)
+
+	metrics := poker.NewMetrics()
+	store = poker.NewMetricsPlayerStore(store, metrics)
+
+	if cacheTTL := time.Duration(config.Store.CacheTTL); cacheTTL > 0 {
+		cache := poker.NewCachingPlayerStore(store, cacheTTL)
+		metrics.GaugeFunc("poker_store_cache_hit_ratio", "Fraction of league and score reads answered from the cache.", func() float64 {
+			return cache.Stats().HitRate()
+		})
+		store = cache
+	}
+
+	hooks := poker.NewWebhooks(5, time.Second)
+	// Closers run last first, so deliveries still going out, like the win
+	// of a game that has just finished, get until the shutdown deadline.
+	closers = append(closers, hooks.Wait)
+	broadcaster := poker.NewBroadcaster()
+	alerter := poker.NewScheduledAlerter(poker.JSONSink, poker.LoggerSink(logger), hooks, broadcaster, metrics)
+	hookedStore := poker.NewWebhookPlayerStore(store, hooks)
+	holdem := poker.NewTexasHoldem(alerter, hookedStore)
+	holdem.SetBlinds(config.Blinds.Amounts, time.Duration(config.Blinds.LevelLength))
+	holdem.WarnBefore(config.Blinds.WarnBefore()...)
+	game := poker.NewWebhookGame(holdem, hooks)
+	options := []poker.ServerOption{
+		poker.WithWebhooks(hooks),
+		poker.WithBroadcaster(broadcaster),
+		poker.WithAdminToken(config.AdminToken),
+		poker.WithMetrics(metrics),
+		poker.WithLogger(logger),
+	}
+	if dataDir != "" {
+		options = append(options, poker.WithDataDir(dataDir))
+	}
+	if config.AssetDir != "" {
+		options = append(options, poker.WithAssetDir(config.AssetDir))
+	}
+	if config.Template != "" {
+		options = append(options, poker.WithTemplate(config.Template))
+	}
+	server, err := poker.NewPlayerServer(hookedStore, game, options...)
 
 	if err != nil {
 		log.Fatalf("problem creating player server %v", err)
 	}
 
-	log.Fatal(http.ListenAndServe(":5000", server))
+	httpServer := &http.Server{Addr: config.Addr, Handler: server}
+	if config.TLS.Enabled() {
+		tlsConfig := config.TLS
+		if tlsConfig.SelfSigned && tlsConfig.CertFile == "" && dataDir != "" {
+			// Keep the certificate with the league, so browsers that have
+			// accepted it once don't have to again after a restart.
+			tlsConfig.CertFile = filepath.Join(dataDir, selfSignedCertFileName)
+			tlsConfig.KeyFile = filepath.Join(dataDir, selfSignedKeyFileName)
+		}
+		cert, err := tlsConfig.Certificate(poker.LocalHosts(), time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	go func() {
+		logger.Info("listening", "addr", httpServer.Addr, "tls", httpServer.TLSConfig != nil)
+		var err error
+		if httpServer.TLSConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	if config.TLS.RedirectAddr != "" {
+		redirect := &http.Server{Addr: config.TLS.RedirectAddr, Handler: poker.RedirectToHTTPS(config.Addr)}
+		closers = append(closers, func() { redirect.Close() })
+		go func() {
+			logger.Info("redirecting to HTTPS", "addr", redirect.Addr)
+			if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	stopped, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-stopped.Done()
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout))
+	defer cancel()
+	if err := shutdown(ctx, logger, httpServer, server, game, dataDir, closers); err != nil {
+		logger.Error("problem shutting down", "err", err)
+		os.Exit(1)
+	}
+	logger.Info("shut down")
+}
+
+// shutdown stops new games and requests, says goodbye to the WebSocket
+// clients, saves any game still in progress and then runs closers, such as
+// waiting for webhook deliveries and closing the store, giving up if ctx is
+// done first.
+func shutdown(ctx context.Context, logger *poker.Logger, httpServer *http.Server, server *poker.PlayerServer, game poker.Game, dataDir string, closers []func()) error {
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("WebSocket clients didn't all disconnect", "err", err)
+	}
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Warn("requests were still in flight", "err", err)
+	}
+
+	if dataDir != "" {
+		statePath := filepath.Join(dataDir, gameStateFileName)
+		saved, err := poker.SaveGameState(statePath, game)
+		switch {
+		case err != nil:
+			logger.Error("problem saving the game in progress", "err", err)
+		case saved:
+			logger.Info("saved the game in progress", "path", statePath)
+		default:
+			// Nothing was interrupted this time, so any earlier game is stale.
+			os.Remove(statePath)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhooks and the store didn't finish closing, %v", ctx.Err())
+	}
+}
+
+// reportInterruptedGame logs the game the last shutdown saved, if there is
+// one, so it can be picked up again by hand.
+func reportInterruptedGame(logger *poker.Logger, path string) {
+	state, err := poker.LoadGameState(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		logger.Warn("problem reading the interrupted game", "err", err)
+		return
+	}
+	logger.Warn("the last shutdown interrupted a game",
+		"players", strings.Join(state.Players, ","),
+		"level", state.Level,
+		"blind", state.Blind,
+		"remaining", state.Remaining,
+		"saved", state.Saved,
+	)
+}
+
+func openFileStore(path string, backups poker.BackupConfig) (*poker.FileSystemPlayerStore, func()) {
+	store, closeFunc, err := poker.FileSystemStoreFromFile(path)
+	if err != nil && backups.Recover && errors.Is(err, poker.ErrCorruptLeague) {
+		poker.DefaultLogger.Warn("recovering from the latest backup", "err", err)
+		backup, recoverErr := poker.QuarantineLeague(path, backups.Dir, time.Now())
+		if recoverErr != nil {
+			log.Fatalf("problem recovering from backup, %v", recoverErr)
+		}
+		poker.DefaultLogger.Info("restored player database", "path", path, "backup", backup)
+		store, closeFunc, err = poker.FileSystemStoreFromFile(path)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store, closeFunc
 }
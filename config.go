@@ -0,0 +1,422 @@
+package poker
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+const (
+	StoreBackendFile   = "file"
+	StoreBackendMemory = "memory"
+)
+
+// Config is everything the server and CLI binaries can be set up with. It
+// starts from DefaultConfig and is overridden by a JSON config file, then
+// POKER_* environment variables, then command line flags.
+type Config struct {
+	Addr       string
+	AdminToken string `json:",omitempty"`
+	// Template is a game page to use instead of the one built in, and
+	// AssetDir a directory to serve it and static/ from, reloading them on
+	// every request.
+	Template        string `json:",omitempty"`
+	AssetDir        string `json:",omitempty"`
+	ShutdownTimeout ConfigDuration
+	Store           StoreConfig
+	Blinds          BlindsConfig
+	Log             LogConfig
+	Backup          BackupConfig
+	TLS             TLSConfig
+}
+
+type StoreConfig struct {
+	// Backend is StoreBackendFile, or StoreBackendMemory for a league that
+	// is thrown away when the server stops.
+	Backend     string
+	Path        string
+	WriteBehind ConfigDuration
+	CacheTTL    ConfigDuration
+}
+
+type BlindsConfig struct {
+	Amounts []int
+	// LevelLength is how long each level lasts, or zero for five minutes plus
+	// one for every player.
+	LevelLength ConfigDuration
+	// Warnings are how long before each blind increase players are warned
+	// about it. Leaving them empty only announces each level as it starts.
+	Warnings []ConfigDuration
+}
+
+// WarnBefore is Warnings as durations, for TexasHoldem.WarnBefore.
+func (b BlindsConfig) WarnBefore() []time.Duration {
+	warnings := make([]time.Duration, len(b.Warnings))
+	for i, warning := range b.Warnings {
+		warnings[i] = time.Duration(warning)
+	}
+	return warnings
+}
+
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+type BackupConfig struct {
+	Dir     string
+	Every   ConfigDuration
+	Keep    int
+	Recover bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":5000",
+		ShutdownTimeout: ConfigDuration(10 * time.Second),
+		Store: StoreConfig{
+			Backend: StoreBackendFile,
+			Path:    "game.db.json",
+		},
+		Blinds: BlindsConfig{
+			Amounts:  append([]int{}, defaultBlinds...),
+			Warnings: []ConfigDuration{ConfigDuration(DefaultBlindWarnings[0]), ConfigDuration(DefaultBlindWarnings[1])},
+		},
+		Log: LogConfig{Level: LevelInfo.String(), Format: LogFormatText},
+		Backup: BackupConfig{
+			Dir:   "backups",
+			Every: ConfigDuration(time.Hour),
+			Keep:  24,
+		},
+	}
+}
+
+// LoadConfig works out the configuration for the command called name from
+// its args, the environment as seen through lookupEnv, and the config file
+// named by the -config flag or POKER_CONFIG, in that order of precedence. It
+// returns the arguments left after the flags, and the config only once it
+// has been validated.
+func LoadConfig(name string, args []string, lookupEnv func(string) (string, bool)) (Config, []string, error) {
+	// The flags are read once just to find the config file, as it has to be
+	// loaded before the flags can override it.
+	path, _ := lookupEnv("POKER_CONFIG")
+	var scratch Config
+	prescan := scratch.flagSet(name, &path)
+	prescan.SetOutput(io.Discard)
+	prescan.Parse(args)
+
+	config := DefaultConfig()
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return config, nil, err
+		}
+	}
+	if err := config.loadEnv(lookupEnv); err != nil {
+		return config, nil, err
+	}
+
+	flags := config.flagSet(name, &path)
+	if err := flags.Parse(args); err != nil {
+		return config, nil, err
+	}
+	return config, flags.Args(), config.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("problem opening config file %s, %v", path, err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("problem reading config file %s, %v", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv(lookupEnv func(string) (string, bool)) error {
+	for _, setting := range c.settings() {
+		value, ok := lookupEnv(setting.env)
+		if !ok {
+			continue
+		}
+		if err := setting.value.Set(value); err != nil {
+			return fmt.Errorf("problem reading %s, %v", setting.env, err)
+		}
+	}
+	return nil
+}
+
+// flagSet has a flag for each setting, defaulting to its value in c so that
+// only the flags actually given change anything.
+func (c *Config) flagSet(name string, path *string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(path, "config", *path, "JSON config file to read before the environment and flags (POKER_CONFIG)")
+	for _, setting := range c.settings() {
+		flags.Var(setting.value, setting.flag, fmt.Sprintf("%s (%s)", setting.usage, setting.env))
+	}
+	return flags
+}
+
+type configSetting struct {
+	flag, env, usage string
+	value            flag.Value
+}
+
+func (c *Config) settings() []configSetting {
+	return []configSetting{
+		{"addr", "POKER_ADDR", "address to listen on", (*stringSetting)(&c.Addr)},
+		{"admin-token", "POKER_ADMIN_TOKEN", "bearer token for the admin endpoints, which are off without one", (*stringSetting)(&c.AdminToken)},
+		{"template", "POKER_TEMPLATE", "template for the game page instead of the one built in", (*stringSetting)(&c.Template)},
+		{"asset-dir", "POKER_ASSET_DIR", "serve game.html and static/ from this directory, reloading them on every request, for working on the page", (*stringSetting)(&c.AssetDir)},
+		{"shutdown-timeout", "POKER_SHUTDOWN_TIMEOUT", "how long to wait for games and the store to wind down on SIGTERM before exiting anyway", &c.ShutdownTimeout},
+		{"store", "POKER_STORE", "file, or memory for a league that is thrown away when the server stops", (*stringSetting)(&c.Store.Backend)},
+		{"db", "POKER_DB", "player database file", (*stringSetting)(&c.Store.Path)},
+		{"write-behind", "POKER_WRITE_BEHIND", "batch changes to the store and write them this often, 0 to write each one straight away", &c.Store.WriteBehind},
+		{"cache-ttl", "POKER_CACHE_TTL", "how long to cache league and score reads, 0 to not cache them", &c.Store.CacheTTL},
+		{"blinds", "POKER_BLINDS", "comma separated blind amounts, one for each level", (*blindsSetting)(&c.Blinds.Amounts)},
+		{"blind-level", "POKER_BLIND_LEVEL", "how long each blind level lasts, 0 for five minutes plus one for every player", &c.Blinds.LevelLength},
+		{"blind-warnings", "POKER_BLIND_WARNINGS", "comma separated times before each blind increase to warn players, empty for none", (*warningsSetting)(&c.Blinds.Warnings)},
+		{"log-level", "POKER_LOG_LEVEL", "least severe log lines to write: debug, info, warn or error", (*stringSetting)(&c.Log.Level)},
+		{"log-format", "POKER_LOG_FORMAT", "text for key=value log lines, or json", (*stringSetting)(&c.Log.Format)},
+		{"backup-dir", "POKER_BACKUP_DIR", "directory to save scheduled backups in", (*stringSetting)(&c.Backup.Dir)},
+		{"backup-every", "POKER_BACKUP_EVERY", "how often to back up the player database, 0 to never", &c.Backup.Every},
+		{"backup-keep", "POKER_BACKUP_KEEP", "how many scheduled backups to keep, 0 for all of them", (*intSetting)(&c.Backup.Keep)},
+		{"tls-cert", "POKER_TLS_CERT", "serve HTTPS with this certificate file", (*stringSetting)(&c.TLS.CertFile)},
+		{"tls-key", "POKER_TLS_KEY", "key file for the -tls-cert certificate", (*stringSetting)(&c.TLS.KeyFile)},
+		{"tls-self-signed", "POKER_TLS_SELF_SIGNED", "serve HTTPS with a self-signed certificate for LAN use, kept in -tls-cert and -tls-key if they are set", (*boolSetting)(&c.TLS.SelfSigned)},
+		{"redirect-addr", "POKER_REDIRECT_ADDR", "address to listen on for plain HTTP and redirect it to HTTPS, empty to not", (*stringSetting)(&c.TLS.RedirectAddr)},
+		{"recover-from-backup", "POKER_RECOVER_FROM_BACKUP", "if the player database is corrupt, move it aside and start from the latest backup", (*boolSetting)(&c.Backup.Recover)},
+	}
+}
+
+// Validate returns every problem with the config at once.
+func (c Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		problem("Addr %q isn't a host:port, %v", c.Addr, err)
+	}
+	if c.AssetDir != "" {
+		if info, err := os.Stat(c.AssetDir); err != nil || !info.IsDir() {
+			problem("AssetDir %q isn't a directory", c.AssetDir)
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		problem("ShutdownTimeout has to be more than 0")
+	}
+
+	switch c.Store.Backend {
+	case StoreBackendFile:
+		if c.Store.Path == "" {
+			problem("Store.Path is empty")
+		}
+	case StoreBackendMemory:
+	default:
+		problem("Store.Backend %q isn't %s or %s", c.Store.Backend, StoreBackendFile, StoreBackendMemory)
+	}
+	if c.Store.WriteBehind < 0 || c.Store.CacheTTL < 0 {
+		problem("Store.WriteBehind and Store.CacheTTL can't be negative")
+	}
+
+	if len(c.Blinds.Amounts) == 0 {
+		problem("Blinds.Amounts is empty")
+	}
+	for i, amount := range c.Blinds.Amounts {
+		if amount <= 0 {
+			problem("Blinds.Amounts level %d is %d, it has to be more than 0", i+1, amount)
+		} else if i > 0 && amount <= c.Blinds.Amounts[i-1] {
+			problem("Blinds.Amounts level %d is %d, it has to be more than the level before", i+1, amount)
+		}
+	}
+	if c.Blinds.LevelLength < 0 {
+		problem("Blinds.LevelLength can't be negative")
+	}
+	for i, warning := range c.Blinds.Warnings {
+		if warning <= 0 {
+			problem("Blinds.Warnings %d is %v, it has to be more than 0", i+1, warning)
+		}
+	}
+
+	if _, err := ParseLogLevel(c.Log.Level); err != nil {
+		problem("Log.Level: %v", err)
+	}
+	if c.Log.Format != LogFormatText && c.Log.Format != LogFormatJSON {
+		problem("Log.Format %q isn't %s or %s", c.Log.Format, LogFormatText, LogFormatJSON)
+	}
+
+	if c.Backup.Every < 0 {
+		problem("Backup.Every can't be negative")
+	}
+	if c.Backup.Keep < 0 {
+		problem("Backup.Keep can't be negative")
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		problem("TLS.CertFile and TLS.KeyFile have to be set together")
+	}
+	if c.TLS.CertFile != "" && !c.TLS.SelfSigned {
+		for _, file := range []string{c.TLS.CertFile, c.TLS.KeyFile} {
+			if _, err := os.Stat(file); err != nil {
+				problem("TLS certificate file %q can't be read, %v", file, err)
+			}
+		}
+	}
+	if c.TLS.RedirectAddr != "" {
+		if !c.TLS.Enabled() {
+			problem("TLS.RedirectAddr is set but there is no certificate to serve HTTPS with")
+		}
+		if _, _, err := net.SplitHostPort(c.TLS.RedirectAddr); err != nil {
+			problem("TLS.RedirectAddr %q isn't a host:port, %v", c.TLS.RedirectAddr, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Logger makes the Logger the config describes, writing to out.
+func (c Config) Logger(out io.Writer) *Logger {
+	level, err := ParseLogLevel(c.Log.Level)
+	if err != nil {
+		level = LevelInfo
+	}
+	return NewLogger(out, level, c.Log.Format)
+}
+
+// WriteConfig writes config as indented JSON, which can be used as a config
+// file. The admin token is left out, so it has to be given again.
+func WriteConfig(w io.Writer, config Config) error {
+	config.AdminToken = ""
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config); err != nil {
+		return fmt.Errorf("problem writing config, %v", err)
+	}
+	return nil
+}
+
+// ConfigDuration is a time.Duration written as a string like "1m30s" in
+// config files and flags.
+type ConfigDuration time.Duration
+
+func (d ConfigDuration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *ConfigDuration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = ConfigDuration(parsed)
+	return nil
+}
+
+func (d ConfigDuration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *ConfigDuration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+type stringSetting string
+
+func (s *stringSetting) String() string     { return string(*s) }
+func (s *stringSetting) Set(v string) error { *s = stringSetting(v); return nil }
+
+type intSetting int
+
+func (i *intSetting) String() string { return strconv.Itoa(int(*i)) }
+
+func (i *intSetting) Set(v string) error {
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%q isn't a whole number", v)
+	}
+	*i = intSetting(parsed)
+	return nil
+}
+
+type boolSetting bool
+
+func (b *boolSetting) String() string   { return strconv.FormatBool(bool(*b)) }
+func (b *boolSetting) IsBoolFlag() bool { return true }
+
+func (b *boolSetting) Set(v string) error {
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%q isn't true or false", v)
+	}
+	*b = boolSetting(parsed)
+	return nil
+}
+
+type blindsSetting []int
+
+func (b *blindsSetting) String() string {
+	amounts := make([]string, len(*b))
+	for i, amount := range *b {
+		amounts[i] = strconv.Itoa(amount)
+	}
+	return strings.Join(amounts, ",")
+}
+
+func (b *blindsSetting) Set(v string) error {
+	var amounts []int
+	for _, field := range strings.Split(v, ",") {
+		amount, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return fmt.Errorf("%q isn't a comma separated list of amounts", v)
+		}
+		amounts = append(amounts, amount)
+	}
+	*b = amounts
+	return nil
+}
+
+type warningsSetting []ConfigDuration
+
+func (w *warningsSetting) String() string {
+	warnings := make([]string, len(*w))
+	for i, warning := range *w {
+		warnings[i] = warning.String()
+	}
+	return strings.Join(warnings, ",")
+}
+
+func (w *warningsSetting) Set(v string) error {
+	warnings := []ConfigDuration{}
+	if strings.TrimSpace(v) == "" {
+		*w = warnings
+		return nil
+	}
+	for _, field := range strings.Split(v, ",") {
+		var warning ConfigDuration
+		if err := warning.Set(strings.TrimSpace(field)); err != nil {
+			return fmt.Errorf("%q isn't a comma separated list of durations", v)
+		}
+		warnings = append(warnings, warning)
+	}
+	*w = warnings
+	return nil
+}
@@ -0,0 +1,156 @@
+package poker
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("uses the defaults with nothing set", func(t *testing.T) {
+		config, args, err := LoadConfig("webserver", nil, noEnv)
+		assertNoError(t, err)
+
+		if !reflect.DeepEqual(config, DefaultConfig()) {
+			t.Errorf("got %+v, want the defaults %+v", config, DefaultConfig())
+		}
+		if len(args) != 0 {
+			t.Errorf("got args %v, want none", args)
+		}
+	})
+	t.Run("flags beat the environment, which beats the config file", func(t *testing.T) {
+		path := writeConfigFile(t, `{"Addr": ":7000", "Store": {"Path": "league.json"}, "Log": {"Level": "warn"}, "Blinds": {"LevelLength": "20m"}}`)
+		env := fakeEnv{"POKER_CONFIG": path, "POKER_ADDR": ":8000", "POKER_LOG_LEVEL": "debug"}
+
+		config, args, err := LoadConfig("webserver", []string{"-log-level", "error", "-blinds", "50, 100,200", "config"}, env.lookup)
+		assertNoError(t, err)
+
+		if config.Addr != ":8000" {
+			t.Errorf("got Addr %q, want the environment's", config.Addr)
+		}
+		if config.Store.Path != "league.json" || config.Blinds.LevelLength != ConfigDuration(20*time.Minute) {
+			t.Errorf("got %+v and %+v, want the config file's", config.Store, config.Blinds)
+		}
+		if config.Log.Level != "error" || !reflect.DeepEqual(config.Blinds.Amounts, []int{50, 100, 200}) {
+			t.Errorf("got %+v and %v, want the flags'", config.Log, config.Blinds.Amounts)
+		}
+		if config.Backup.Dir != "backups" {
+			t.Errorf("got Backup.Dir %q, want the default kept", config.Backup.Dir)
+		}
+		if !reflect.DeepEqual(args, []string{"config"}) {
+			t.Errorf("got args %v, want [config]", args)
+		}
+	})
+	t.Run("sets the blind warnings, or none at all", func(t *testing.T) {
+		config, _, err := LoadConfig("webserver", nil, fakeEnv{"POKER_BLIND_WARNINGS": "2m, 30s"}.lookup)
+		assertNoError(t, err)
+		if got := config.Blinds.WarnBefore(); !reflect.DeepEqual(got, []time.Duration{2 * time.Minute, 30 * time.Second}) {
+			t.Errorf("got warnings %v, want the environment's", got)
+		}
+
+		config, _, err = LoadConfig("webserver", []string{"-blind-warnings", ""}, noEnv)
+		assertNoError(t, err)
+		if got := config.Blinds.WarnBefore(); len(got) != 0 {
+			t.Errorf("got warnings %v, want none", got)
+		}
+	})
+	t.Run("reads the config file named by the flag", func(t *testing.T) {
+		path := writeConfigFile(t, `{"Store": {"Backend": "memory"}}`)
+
+		config, _, err := LoadConfig("webserver", []string{"-config", path}, noEnv)
+		assertNoError(t, err)
+
+		if config.Store.Backend != StoreBackendMemory {
+			t.Errorf("got Backend %q, want memory", config.Store.Backend)
+		}
+	})
+	t.Run("reports unknown fields in the config file", func(t *testing.T) {
+		path := writeConfigFile(t, `{"Port": 5000}`)
+
+		_, _, err := LoadConfig("webserver", []string{"-config", path}, noEnv)
+		if err == nil || !strings.Contains(err.Error(), "Port") {
+			t.Errorf("got %v, want an error about Port", err)
+		}
+	})
+	t.Run("reports bad environment variables by name", func(t *testing.T) {
+		env := fakeEnv{"POKER_WRITE_BEHIND": "soon"}
+
+		_, _, err := LoadConfig("webserver", nil, env.lookup)
+		if err == nil || !strings.Contains(err.Error(), "POKER_WRITE_BEHIND") {
+			t.Errorf("got %v, want an error about POKER_WRITE_BEHIND", err)
+		}
+	})
+	t.Run("validates what it ends up with", func(t *testing.T) {
+		_, _, err := LoadConfig("webserver", []string{"-addr", "5000", "-store", "postgres", "-blinds", "100,50", "-blind-warnings", "1m,-5s", "-log-format", "xml", "-asset-dir", "/no/such/dir"}, noEnv)
+
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("got %v, want %v", err, ErrInvalidConfig)
+		}
+		for _, want := range []string{"Addr", "Store.Backend", "Blinds.Amounts level 2", "Blinds.Warnings 2", "Log.Format", "AssetDir"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q doesn't mention %s", err, want)
+			}
+		}
+	})
+}
+
+func TestWriteConfig(t *testing.T) {
+	t.Run("writes a config file that loads back the same", func(t *testing.T) {
+		want := DefaultConfig()
+		want.Store.WriteBehind = ConfigDuration(5 * time.Second)
+		want.Blinds.Amounts = []int{25, 50, 100}
+		want.Blinds.Warnings = []ConfigDuration{ConfigDuration(90 * time.Second)}
+		want.Backup.Recover = true
+
+		var out bytes.Buffer
+		assertNoError(t, WriteConfig(&out, want))
+		path := writeConfigFile(t, out.String())
+
+		got, _, err := LoadConfig("webserver", []string{"-config", path}, noEnv)
+		assertNoError(t, err)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+	t.Run("hides the admin token", func(t *testing.T) {
+		config := DefaultConfig()
+		config.AdminToken = "s3cret"
+
+		var out bytes.Buffer
+		assertNoError(t, WriteConfig(&out, config))
+
+		if strings.Contains(out.String(), "s3cret") {
+			t.Errorf("config %s gives away the admin token", out.String())
+		}
+		got, _, err := LoadConfig("webserver", []string{"-config", writeConfigFile(t, out.String())}, noEnv)
+		assertNoError(t, err)
+		if got.AdminToken != "" {
+			t.Errorf("got admin token %q loading the config back, want none", got.AdminToken)
+		}
+	})
+}
+
+type fakeEnv map[string]string
+
+func (e fakeEnv) lookup(name string) (string, bool) {
+	value, ok := e[name]
+	return value, ok
+}
+
+func noEnv(string) (string, bool) {
+	return "", false
+}
+
+func writeConfigFile(t testing.TB, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "poker.json")
+	if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatalf("could not write config file, %v", err)
+	}
+	return path
+}
@@ -0,0 +1,270 @@
+package poker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	EventPlayerRegisteredType   = "PlayerRegistered"
+	EventWinRecordedType        = "WinRecorded"
+	EventWinRevertedType        = "WinReverted"
+	EventPlayerRenamedType      = "PlayerRenamed"
+	EventAttendanceRecordedType = "AttendanceRecorded"
+	EventPlayersImportedType    = "PlayersImported"
+	EventSnapshotType           = "Snapshot"
+)
+
+var ErrNothingToUndo = errors.New("there are no recorded wins left to undo")
+
+// Event is one entry in an EventLog. Reverts is the Seq of the event a
+// WinReverted takes back, NewName is what a PlayerRenamed changes Player to
+// and League is the whole table as of a Snapshot or the results added by a
+// PlayersImported.
+type Event struct {
+	Seq     int
+	Type    string
+	Player  string `json:",omitempty"`
+	Reverts int    `json:",omitempty"`
+	NewName string `json:",omitempty"`
+	League  League `json:",omitempty"`
+	Time    time.Time
+}
+
+// EventLog is an append-only record of the results a store has been given,
+// kept as one JSON event per line so corrections can be audited. Events
+// compacted out of it are kept, and written to an archive file if it has
+// one, so its History stays whole.
+type EventLog struct {
+	mu       sync.Mutex
+	out      io.Writer
+	file     *os.File
+	events   []Event
+	archive  *os.File
+	archived []Event
+}
+
+// NewEventLog reads the events already in file and appends new ones to its
+// end. A nil file keeps the log in memory only.
+func NewEventLog(file *os.File) (*EventLog, error) {
+	if file == nil {
+		return &EventLog{out: io.Discard}, nil
+	}
+
+	events, err := readEvents(file)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{out: file, file: file, events: events}, nil
+}
+
+// ArchiveTo has Compact move the events it drops to the end of file, and
+// reads back the ones it moved there before.
+func (l *EventLog) ArchiveTo(file *os.File) error {
+	archived, err := readEvents(file)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.archive = file
+	l.archived = append(archived, l.archived...)
+	return nil
+}
+
+// readEvents reads every event in file, leaving it ready to append to.
+func readEvents(file *os.File) ([]Event, error) {
+	file.Seek(0, 0)
+	var events []Event
+	decoder := json.NewDecoder(file)
+	for {
+		var event Event
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("problem reading event %d from %s near byte %d (%v), %w", len(events)+1, file.Name(), decoder.InputOffset(), err, ErrCorruptLeague)
+		}
+		events = append(events, event)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return nil, fmt.Errorf("problem seeking to the end of %s, %v", file.Name(), err)
+	}
+	return events, nil
+}
+
+func (l *EventLog) Append(event Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event.Seq = len(l.events) + 1
+	if len(l.events) > 0 {
+		event.Seq = l.events[len(l.events)-1].Seq + 1
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	if err := json.NewEncoder(l.out).Encode(event); err != nil {
+		return Event{}, fmt.Errorf("problem appending %s event, %v", event.Type, err)
+	}
+	l.events = append(l.events, event)
+	return event, nil
+}
+
+func (l *EventLog) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event{}, l.events...)
+}
+
+func (l *EventLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events)
+}
+
+// LastUndoableWin finds the most recent win that hasn't already been
+// reverted, so repeated undos keep going further back. The win comes back
+// under the player's current name if they've been renamed since.
+func (l *EventLog) LastUndoableWin() (Event, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reverted := map[int]bool{}
+	for i := len(l.events) - 1; i >= 0; i-- {
+		event := l.events[i]
+		switch {
+		case event.Type == EventWinRevertedType:
+			reverted[event.Reverts] = true
+		case event.Type == EventWinRecordedType && !reverted[event.Seq]:
+			for _, later := range l.events[i+1:] {
+				if later.Type == EventPlayerRenamedType && later.Player == event.Player {
+					event.Player = later.NewName
+				}
+			}
+			return event, true
+		}
+	}
+	return Event{}, false
+}
+
+// History is every event the log has had, including those compacted out of
+// it, oldest first.
+func (l *EventLog) History() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append(l.archivedBefore(), l.events...)
+}
+
+// Archived is the events compacted out of the log, oldest first.
+func (l *EventLog) Archived() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.archivedBefore()
+}
+
+func (l *EventLog) archivedBefore() []Event {
+	archived := make([]Event, 0, len(l.archived)+len(l.events))
+	for _, event := range l.archived {
+		// After a restore from backup the log can start earlier than the
+		// archive ends.
+		if len(l.events) == 0 || event.Seq < l.events[0].Seq {
+			archived = append(archived, event)
+		}
+	}
+	return archived
+}
+
+// Compact drops every event before the latest snapshot, rewriting the file
+// in place and moving them to the archive. Wins from before the snapshot
+// can no longer be undone.
+func (l *EventLog) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := lastSnapshot(l.events)
+	if start <= 0 {
+		return nil
+	}
+	dropped := l.events[:start]
+	kept := append([]Event{}, l.events[start:]...)
+
+	if l.archive != nil {
+		encoder := json.NewEncoder(l.archive)
+		for _, event := range dropped {
+			if err := encoder.Encode(event); err != nil {
+				return fmt.Errorf("problem archiving event %d to %s, %v", event.Seq, l.archive.Name(), err)
+			}
+		}
+		if err := l.archive.Sync(); err != nil {
+			return fmt.Errorf("problem syncing %s, %v", l.archive.Name(), err)
+		}
+	}
+
+	if l.file != nil {
+		if err := l.file.Truncate(0); err != nil {
+			return fmt.Errorf("problem truncating %s, %v", l.file.Name(), err)
+		}
+		if _, err := l.file.Seek(0, 0); err != nil {
+			return fmt.Errorf("problem rewinding %s, %v", l.file.Name(), err)
+		}
+		encoder := json.NewEncoder(l.file)
+		for _, event := range kept {
+			if err := encoder.Encode(event); err != nil {
+				return fmt.Errorf("problem rewriting event %d to %s, %v", event.Seq, l.file.Name(), err)
+			}
+		}
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("problem syncing %s, %v", l.file.Name(), err)
+		}
+	}
+
+	l.archived = append(l.archived, dropped...)
+	l.events = kept
+	return nil
+}
+
+// ReplayEvents rebuilds a league from the latest snapshot in events and
+// everything that happened after it.
+func ReplayEvents(events []Event) League {
+	league := League{}
+	start := lastSnapshot(events)
+	if start < 0 {
+		start = 0
+	}
+	for _, event := range events[start:] {
+		league = league.Apply(event)
+	}
+	return league
+}
+
+func lastSnapshot(events []Event) int {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == EventSnapshotType {
+			return i
+		}
+	}
+	return -1
+}
+
+// CorrectLastWin takes back the last recorded win and gives it to winner
+// instead, returning who lost it.
+func CorrectLastWin(store PlayerStore, winner string) (string, error) {
+	undone, err := store.UndoLastWin()
+	if err != nil {
+		return "", err
+	}
+	store.RecordWin(winner)
+	return undone, nil
+}
@@ -0,0 +1,91 @@
+package poker
+
+import (
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	t.Run("reads back the events it appended", func(t *testing.T) {
+		file, clean := createTempFile(t, "")
+		defer clean()
+
+		events, err := NewEventLog(file)
+		assertNoError(t, err)
+		events.Append(Event{Type: EventWinRecordedType, Player: "Paul"})
+		events.Append(Event{Type: EventWinRecordedType, Player: "Rand"})
+
+		reopened, err := NewEventLog(file)
+		assertNoError(t, err)
+
+		got := reopened.Events()
+		if len(got) != 2 || got[1].Seq != 2 || got[1].Player != "Rand" {
+			t.Errorf("got events %+v", got)
+		}
+	})
+	t.Run("undoes wins from the most recent backwards", func(t *testing.T) {
+		events, _ := NewEventLog(nil)
+		paul, _ := events.Append(Event{Type: EventWinRecordedType, Player: "Paul"})
+		rand, _ := events.Append(Event{Type: EventWinRecordedType, Player: "Rand"})
+
+		assertUndoableWin(t, events, rand)
+		events.Append(Event{Type: EventWinRevertedType, Player: "Rand", Reverts: rand.Seq})
+		assertUndoableWin(t, events, paul)
+		events.Append(Event{Type: EventWinRevertedType, Player: "Paul", Reverts: paul.Seq})
+
+		if _, ok := events.LastUndoableWin(); ok {
+			t.Error("expected nothing left to undo")
+		}
+	})
+	t.Run("rejects a log it can't read", func(t *testing.T) {
+		file, clean := createTempFile(t, "{\"Seq\": 1}\nnot json\n")
+		defer clean()
+
+		_, err := NewEventLog(file)
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+	t.Run("replays from the latest snapshot", func(t *testing.T) {
+		events := []Event{
+			{Type: EventWinRecordedType, Player: "Ignored"},
+			{Type: EventSnapshotType, League: League{{Name: "Paul", Wins: 2}}},
+			{Type: EventPlayerRegisteredType, Player: "Rand"},
+			{Seq: 4, Type: EventWinRecordedType, Player: "Rand"},
+			{Type: EventAttendanceRecordedType, Player: "Rand"},
+			{Type: EventPlayerRenamedType, Player: "Paul", NewName: "Paula"},
+			{Type: EventWinRevertedType, Player: "Paula", Reverts: 1},
+		}
+
+		assertLeague(t, ReplayEvents(events), []Player{
+			{Name: "Paula", Wins: 1},
+			{Name: "Rand", Wins: 1, Games: 1},
+		})
+	})
+	t.Run("compacts everything before the latest snapshot", func(t *testing.T) {
+		file, clean := createTempFile(t, "")
+		defer clean()
+
+		events, _ := NewEventLog(file)
+		events.Append(Event{Type: EventWinRecordedType, Player: "Paul"})
+		events.Append(Event{Type: EventSnapshotType, League: League{{Name: "Paul", Wins: 1}}})
+		events.Append(Event{Type: EventWinRecordedType, Player: "Rand"})
+		assertNoError(t, events.Compact())
+
+		reopened, err := NewEventLog(file)
+		assertNoError(t, err)
+
+		got := reopened.Events()
+		if len(got) != 2 || got[0].Type != EventSnapshotType || got[1].Seq != 3 {
+			t.Errorf("got events %+v", got)
+		}
+		assertLeague(t, ReplayEvents(got), []Player{{Name: "Paul", Wins: 1}, {Name: "Rand", Wins: 1}})
+	})
+}
+
+func assertUndoableWin(t testing.TB, events *EventLog, want Event) {
+	t.Helper()
+	got, ok := events.LastUndoableWin()
+	if !ok || got.Seq != want.Seq {
+		t.Errorf("got %+v as the win to undo, want %+v", got, want)
+	}
+}
@@ -2,16 +2,46 @@ package poker
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"sync"
 )
 
 type FileSystemPlayerStore struct {
+	mu       sync.Mutex
+	file     *os.File
 	database *json.Encoder
 	league   League
+	events   *EventLog
+
+	snapshotEvery int
+	compactAfter  int
+	sinceSnapshot int
+
+	logger *Logger
 }
 
+const (
+	eventLogSuffix = ".log"
+	// eventArchiveSuffix names the file compacted events are moved to, so
+	// the store's history is never lost.
+	eventArchiveSuffix = ".archive"
+
+	// defaultSnapshotEvery is how many events go into the log between
+	// snapshots of the league, and defaultCompactAfter how long the log can
+	// grow before everything ahead of the latest snapshot is dropped.
+	defaultSnapshotEvery = 100
+	defaultCompactAfter  = 1000
+)
+
+var (
+	ErrPlayerNotFound = errors.New("no player by that name")
+	ErrPlayerExists   = errors.New("a player by that name already exists")
+)
+
 func FileSystemStoreFromFile(path string) (*FileSystemPlayerStore, func(), error) {
 	db, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
 
@@ -19,33 +49,97 @@ func FileSystemStoreFromFile(path string) (*FileSystemPlayerStore, func(), error
 		return nil, nil, fmt.Errorf("problems opening file %s, %v", path, err)
 	}
 
+	eventLog, err := os.OpenFile(path+eventLogSuffix, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("problems opening file %s, %v", path+eventLogSuffix, err)
+	}
+
+	archive, err := os.OpenFile(path+eventArchiveSuffix, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+
+	if err != nil {
+		db.Close()
+		eventLog.Close()
+		return nil, nil, fmt.Errorf("problems opening file %s, %v", path+eventArchiveSuffix, err)
+	}
+
 	closeFunc := func() {
 		db.Close()
+		eventLog.Close()
+		archive.Close()
 	}
 
-	store, err := NewFileSystemPlayerStore(db)
+	store, err := NewFileSystemPlayerStoreWithLog(db, eventLog)
+	if err == nil {
+		err = store.events.ArchiveTo(archive)
+	}
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("problem creating file system player store, %v", err)
+		closeFunc()
+		return nil, nil, fmt.Errorf("problem creating file system player store, %w", err)
 	}
 	return store, closeFunc, nil
 }
 
 func NewFileSystemPlayerStore(file *os.File) (*FileSystemPlayerStore, error) {
+	return NewFileSystemPlayerStoreWithLog(file, nil)
+}
+
+// NewFileSystemPlayerStoreWithLog is NewFileSystemPlayerStore with every
+// change also appended to eventLog. Once the log holds a snapshot the league
+// is rebuilt by replaying it and file is only kept as a copy of the result,
+// rewritten from the log when it is opened, so a corrupt copy is replaced
+// rather than refused. Until then file is read as before and snapshotted
+// into the log. Files in an older schema version are rewritten in the
+// current one.
+func NewFileSystemPlayerStoreWithLog(file, eventLog *os.File) (*FileSystemPlayerStore, error) {
 	err := initializaPlayerDBFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("problem initializing player db file, %v", err)
 	}
-	league, err := NewLeague(file)
+
+	events, err := NewEventLog(eventLog)
 
 	if err != nil {
-		return nil, fmt.Errorf("problem loading player store from file %s, %v", file.Name(), err)
+		return nil, fmt.Errorf("problem loading event log, %w", err)
+	}
+
+	history := events.Events()
+	last := lastSnapshot(history)
+	dbFile, migrated, err := ReadLeagueFile(file)
+
+	if err != nil && (last < 0 || !errors.Is(err, ErrCorruptLeague)) {
+		return nil, fmt.Errorf("problem loading player store from file %s, %w", file.Name(), err)
+	}
+
+	store := &FileSystemPlayerStore{
+		file:          file,
+		database:      json.NewEncoder(&tape{file}),
+		league:        dbFile.League,
+		events:        events,
+		snapshotEvery: defaultSnapshotEvery,
+		compactAfter:  defaultCompactAfter,
+	}
+
+	if last >= 0 {
+		if err != nil {
+			store.log().Warn("rebuilding the league file from its event log", "path", file.Name(), "err", err)
+		}
+		store.league = ReplayEvents(history)
+		store.sinceSnapshot = len(history) - last - 1
+		store.save()
+		return store, nil
+	}
+
+	if err := store.snapshot(); err != nil {
+		return nil, err
+	}
+	if migrated {
+		store.save()
 	}
 
-	return &FileSystemPlayerStore{
-		database: json.NewEncoder(&tape{file}),
-		league:   league,
-	}, nil
+	return store, nil
 }
 
 func initializaPlayerDBFile(file *os.File) error {
@@ -58,20 +152,26 @@ func initializaPlayerDBFile(file *os.File) error {
 	}
 
 	if info.Size() == 0 {
-		file.Write([]byte("[]"))
+		json.NewEncoder(file).Encode(LeagueFile{Version: LeagueSchemaVersion, League: League{}})
 		file.Seek(0, 0)
 	}
 	return nil
 }
 
 func (f *FileSystemPlayerStore) GetLeague() League {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	sort.Slice(f.league, func(i, j int) bool {
 		return f.league[i].Wins > f.league[j].Wins
 	})
-	return f.league
+	return append(League{}, f.league...)
 }
 
 func (f *FileSystemPlayerStore) GetPlayerScore(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	var wins int
 	player := f.league.Find(name)
 
@@ -83,13 +183,163 @@ func (f *FileSystemPlayerStore) GetPlayerScore(name string) int {
 }
 
 func (f *FileSystemPlayerStore) RecordWin(name string) {
-	player := f.league.Find(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	if player != nil {
-		player.Wins += 1
-	} else {
-		f.league = append(f.league, Player{name, 1})
+	f.register(name)
+	f.record(Event{Type: EventWinRecordedType, Player: name})
+	f.save()
+}
+
+func (f *FileSystemPlayerStore) RecordAttendance(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.register(name)
+	f.record(Event{Type: EventAttendanceRecordedType, Player: name})
+	f.save()
+}
+
+func (f *FileSystemPlayerStore) UndoLastWin() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	win, ok := f.events.LastUndoableWin()
+	if !ok {
+		return "", ErrNothingToUndo
 	}
 
-	f.database.Encode(f.league)
+	f.record(Event{Type: EventWinRevertedType, Player: win.Player, Reverts: win.Seq})
+	f.save()
+	return win.Player, nil
+}
+
+// RenamePlayer moves from's results over to the name to, keeping their
+// history in the log.
+func (f *FileSystemPlayerStore) RenamePlayer(from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.league.Find(from) == nil {
+		return fmt.Errorf("could not rename %s, %w", from, ErrPlayerNotFound)
+	}
+	if f.league.Find(to) != nil {
+		return fmt.Errorf("could not rename %s to %s, %w", from, to, ErrPlayerExists)
+	}
+
+	f.record(Event{Type: EventPlayerRenamedType, Player: from, NewName: to})
+	f.save()
+	return nil
+}
+
+// ImportPlayers adds players' wins and games to the league as one event.
+func (f *FileSystemPlayerStore) ImportPlayers(players []Player) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.record(Event{Type: EventPlayersImportedType, League: append(League{}, players...)})
+	f.save()
+	return nil
+}
+
+// Events is the store's whole history, oldest first, including the events
+// compacted out of its log.
+func (f *FileSystemPlayerStore) Events() []Event {
+	return f.events.History()
+}
+
+// CheckStore checks that the database and its event log are still where the
+// store opened them and can be read, so that one deleted or replaced from
+// under the server shows up before the next write is lost.
+func (f *FileSystemPlayerStore) CheckStore() error {
+	for _, file := range []*os.File{f.file, f.events.file} {
+		if file == nil {
+			continue
+		}
+		if err := checkOpenFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkOpenFile(file *os.File) error {
+	onDisk, err := os.Open(file.Name())
+	if err != nil {
+		return fmt.Errorf("problem opening %s, %v", file.Name(), err)
+	}
+	defer onDisk.Close()
+
+	if _, err := onDisk.Read(make([]byte, 1)); err != nil && err != io.EOF {
+		return fmt.Errorf("problem reading %s, %v", file.Name(), err)
+	}
+	found, err := onDisk.Stat()
+	if err != nil {
+		return fmt.Errorf("problem checking %s, %v", file.Name(), err)
+	}
+	held, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("problem checking %s, %v", file.Name(), err)
+	}
+	if !os.SameFile(found, held) {
+		return fmt.Errorf("%s has been replaced since the store opened it", file.Name())
+	}
+	return nil
+}
+
+func (f *FileSystemPlayerStore) register(name string) {
+	if f.league.Find(name) == nil {
+		f.record(Event{Type: EventPlayerRegisteredType, Player: name})
+	}
+}
+
+// record applies event to the league and appends it to the log, taking a
+// snapshot and compacting once enough events have built up.
+func (f *FileSystemPlayerStore) record(event Event) {
+	if _, err := f.events.Append(event); err != nil {
+		f.log().Error("problem recording event", "type", event.Type, "player", event.Player, "err", err)
+	}
+	f.league = f.league.Apply(event)
+
+	f.sinceSnapshot++
+	if f.sinceSnapshot < f.snapshotEvery {
+		return
+	}
+	if err := f.snapshot(); err != nil {
+		f.log().Error("problem taking snapshot", "err", err)
+		return
+	}
+	if f.events.Len() > f.compactAfter {
+		if err := f.events.Compact(); err != nil {
+			f.log().Error("problem compacting event log", "err", err)
+		}
+	}
+}
+
+// SetLogger sets where the store reports problems writing its files, which
+// is DefaultLogger until it's called.
+func (f *FileSystemPlayerStore) SetLogger(logger *Logger) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logger = logger
+}
+
+func (f *FileSystemPlayerStore) log() *Logger {
+	if f.logger == nil {
+		return DefaultLogger
+	}
+	return f.logger
+}
+
+// save overwrites the database file with the current league.
+func (f *FileSystemPlayerStore) save() {
+	f.database.Encode(LeagueFile{Version: LeagueSchemaVersion, League: f.league})
+}
+
+func (f *FileSystemPlayerStore) snapshot() error {
+	if _, err := f.events.Append(Event{Type: EventSnapshotType, League: append(League{}, f.league...)}); err != nil {
+		return fmt.Errorf("problem snapshotting league, %v", err)
+	}
+	f.sinceSnapshot = 0
+	return nil
 }
@@ -1,8 +1,10 @@
 package poker
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -20,8 +22,8 @@ func TestFileSystemStore(t *testing.T) {
 		got := store.GetLeague()
 
 		want := []Player{
-			{"Chris", 33},
-			{"Cleo", 10},
+			{Name: "Chris", Wins: 33},
+			{Name: "Cleo", Wins: 10},
 		}
 		got = store.GetLeague()
 
@@ -73,6 +75,185 @@ func TestFileSystemStore(t *testing.T) {
 		assertPlayerScore(t, got, want)
 	})
 
+	t.Run("records attendance without adding wins", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[
+			{"Name": "Paul", "Wins": 10, "Games": 12}]`)
+		defer cleanDatabase()
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+		store.RecordAttendance("Paul")
+		store.RecordAttendance("Rand")
+
+		assertLeague(t, store.GetLeague(), []Player{
+			{Name: "Paul", Wins: 10, Games: 13},
+			{Name: "Rand", Games: 1},
+		})
+	})
+
+	t.Run("undoes wins across restarts", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[
+			{"Name": "Paul", "Wins": 10}]`)
+		defer cleanDatabase()
+		eventLog, cleanEventLog := createTempFile(t, "")
+		defer cleanEventLog()
+
+		store, err := NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+		store.RecordWin("Paul")
+		store.RecordWin("Rand")
+
+		store, err = NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+
+		undone, err := store.UndoLastWin()
+		assertNoError(t, err)
+		if undone != "Rand" {
+			t.Errorf("undid a win for %q, want Rand", undone)
+		}
+		assertPlayerScore(t, store.GetPlayerScore("Rand"), 0)
+
+		store.UndoLastWin()
+		assertPlayerScore(t, store.GetPlayerScore("Paul"), 10)
+
+		if _, err := store.UndoLastWin(); err != ErrNothingToUndo {
+			t.Errorf("got %v, want %v", err, ErrNothingToUndo)
+		}
+	})
+
+	t.Run("rebuilds the league by replaying its log", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[
+			{"Name": "Paul", "Wins": 10}]`)
+		defer cleanDatabase()
+		eventLog, cleanEventLog := createTempFile(t, "")
+		defer cleanEventLog()
+
+		store, err := NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+		store.RecordWin("Rand")
+		assertNoError(t, store.RenamePlayer("Paul", "Paula"))
+
+		database.Truncate(0)
+		store, err = NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+
+		assertLeague(t, store.GetLeague(), []Player{
+			{Name: "Paula", Wins: 10},
+			{Name: "Rand", Wins: 1},
+		})
+		if err := store.RenamePlayer("Paula", "Rand"); !errors.Is(err, ErrPlayerExists) {
+			t.Errorf("got %v, want %v", err, ErrPlayerExists)
+		}
+	})
+
+	t.Run("rewrites a corrupt copy of the league from its log", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "game.db.json")
+		store, closeFunc, err := FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		store.RecordWin("Paul")
+		store.RecordWin("Rand")
+		store.RecordWin("Paul")
+		closeFunc()
+
+		data, err := os.ReadFile(path)
+		assertNoError(t, err)
+		assertNoError(t, os.WriteFile(path, data[:len(data)/2], 0666))
+
+		store, closeFunc, err = FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeFunc()
+		assertLeague(t, store.GetLeague(), []Player{{Name: "Paul", Wins: 2}, {Name: "Rand", Wins: 1}})
+
+		data, err = os.ReadFile(path)
+		assertNoError(t, err)
+		if problems := ValidateLeague(data); len(problems) != 0 {
+			t.Errorf("got problems %v with the rewritten copy %s", problems, data)
+		}
+	})
+
+	t.Run("snapshots and compacts its log", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, "")
+		defer cleanDatabase()
+		eventLog, cleanEventLog := createTempFile(t, "")
+		defer cleanEventLog()
+
+		store, err := NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+		store.snapshotEvery = 3
+		store.compactAfter = 4
+		for i := 0; i < 4; i++ {
+			store.RecordWin("Paul")
+		}
+
+		events := store.events.Events()
+		if len(events) != 3 || events[0].Type != EventSnapshotType {
+			t.Errorf("got events %+v", events)
+		}
+		if wins := PlayerWins(store.Events(), "Paul"); len(wins) != 4 {
+			t.Errorf("got %d wins in the history, want all 4 kept through compaction", len(wins))
+		}
+
+		store, err = NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+		assertPlayerScore(t, store.GetPlayerScore("Paul"), 4)
+	})
+
+	t.Run("keeps compacted events in its archive across restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "game.db.json")
+		store, closeFunc, err := FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		store.snapshotEvery = 3
+		store.compactAfter = 4
+		for i := 0; i < 4; i++ {
+			store.RecordAttendance("Paul")
+			store.RecordAttendance("Rand")
+			store.RecordWin("Paul")
+		}
+		closeFunc()
+
+		store, closeFunc, err = FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeFunc()
+
+		games := GameHistory(store.Events())
+		if len(games) != 4 {
+			t.Fatalf("got %d games, want all 4", len(games))
+		}
+		for _, game := range games {
+			if game.Winner != "Paul" || len(game.Players) != 2 {
+				t.Errorf("got game %+v, want Paul beating Rand", game)
+			}
+		}
+	})
+
+	t.Run("migrates a bare array to the versioned format", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[
+			{"Name": "Paul", "Wins": 10}]`)
+		defer cleanDatabase()
+
+		_, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		database.Seek(0, 0)
+		file, migrated, err := ReadLeagueFile(database)
+		assertNoError(t, err)
+		if migrated || file.Version != LeagueSchemaVersion {
+			t.Errorf("got schema version %d, migrated %v, want the file rewritten as %d", file.Version, migrated, LeagueSchemaVersion)
+		}
+		assertLeague(t, file.League, []Player{{Name: "Paul", Wins: 10}})
+	})
+
+	t.Run("refuses files from a newer schema version", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `{"Version": 99, "League": []}`)
+		defer cleanDatabase()
+
+		_, err := NewFileSystemPlayerStore(database)
+
+		if !errors.Is(err, ErrNewerSchemaVersion) {
+			t.Errorf("got %v, want %v", err, ErrNewerSchemaVersion)
+		}
+	})
+
 	t.Run("works with an empty file", func(t *testing.T) {
 		database, cleanDatabase := createTempFile(t, "")
 		defer cleanDatabase()
@@ -96,8 +277,8 @@ func TestFileSystemStore(t *testing.T) {
 		got := store.GetLeague()
 
 		want := []Player{
-			{"Chris", 33},
-			{"Cleo", 10},
+			{Name: "Chris", Wins: 33},
+			{Name: "Cleo", Wins: 10},
 		}
 
 		assertLeague(t, got, want)
@@ -0,0 +1,122 @@
+package poker
+
+import "time"
+
+// GameRecord is one game as pieced together from a store's events: the
+// players whose attendance was recorded together, then the win that
+// followed. A win recorded on its own, not after a game was started, has no
+// Players. Undone is set if the win was reverted afterwards.
+type GameRecord struct {
+	Players  []string `json:",omitempty"`
+	Winner   string   `json:",omitempty"`
+	Started  time.Time
+	Finished time.Time
+	Undone   bool `json:",omitempty"`
+}
+
+// GameHistory rebuilds the games in events, newest first. Names are as the
+// players are known now, after any renames.
+func GameHistory(events []Event) []GameRecord {
+	var games []*GameRecord
+	var open *GameRecord
+	bySeq := map[int]*GameRecord{}
+
+	for _, event := range events {
+		switch event.Type {
+		case EventAttendanceRecordedType:
+			// A name turning up twice means the last game was abandoned.
+			if open == nil || seated(event.Player, open.Players) {
+				open = &GameRecord{Started: event.Time}
+				games = append(games, open)
+			}
+			open.Players = append(open.Players, event.Player)
+		case EventWinRecordedType:
+			game := open
+			if game != nil && seated(event.Player, game.Players) {
+				open = nil
+			} else {
+				game = &GameRecord{Started: event.Time}
+				games = append(games, game)
+			}
+			game.Winner = event.Player
+			game.Finished = event.Time
+			bySeq[event.Seq] = game
+		case EventWinRevertedType:
+			if game, ok := bySeq[event.Reverts]; ok {
+				game.Undone = true
+			}
+		case EventPlayerRenamedType:
+			for _, game := range games {
+				renameIn(game, event.Player, event.NewName)
+			}
+		}
+	}
+
+	history := make([]GameRecord, 0, len(games))
+	for i := len(games) - 1; i >= 0; i-- {
+		history = append(history, *games[i])
+	}
+	return history
+}
+
+// PlayerWins returns when each win still standing for name was recorded,
+// newest first, following the player back through any renames.
+func PlayerWins(events []Event, name string) []time.Time {
+	var wins []time.Time
+	for _, game := range GameHistory(events) {
+		if game.Winner == name && !game.Undone {
+			wins = append(wins, game.Finished)
+		}
+	}
+	return wins
+}
+
+// PlayerGames returns the games name sat down to, newest first.
+func PlayerGames(events []Event, name string) []GameRecord {
+	var played []GameRecord
+	for _, game := range GameHistory(events) {
+		if seated(name, game.Players) {
+			played = append(played, game)
+		}
+	}
+	return played
+}
+
+// historyIsPartial is true when events don't go all the way back: they start
+// from a snapshot of results recorded before there was a log, or results
+// were imported without the games they came from.
+func historyIsPartial(events []Event) bool {
+	for i, event := range events {
+		switch {
+		case event.Type == EventPlayersImportedType:
+			return true
+		case i == 0 && event.Type == EventSnapshotType:
+			for _, player := range event.League {
+				if player.Wins > 0 || player.Games > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func seated(name string, players []string) bool {
+	for _, player := range players {
+		if player == name {
+			return true
+		}
+	}
+	return false
+}
+
+func renameIn(game *GameRecord, from, to string) {
+	for i, player := range game.Players {
+		if player == from {
+			game.Players[i] = to
+		}
+	}
+	if game.Winner == from {
+		game.Winner = to
+	}
+}
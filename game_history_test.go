@@ -0,0 +1,47 @@
+package poker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGameHistory(t *testing.T) {
+	at := func(minutes int) time.Time {
+		return time.Date(2021, time.January, 1, 19, minutes, 0, 0, time.UTC)
+	}
+	events := []Event{
+		{Seq: 1, Type: EventAttendanceRecordedType, Player: "Cleo", Time: at(0)},
+		{Seq: 2, Type: EventAttendanceRecordedType, Player: "Chris", Time: at(0)},
+		{Seq: 3, Type: EventWinRecordedType, Player: "Chris", Time: at(30)},
+		{Seq: 4, Type: EventWinRecordedType, Player: "Pepper", Time: at(31)},
+		{Seq: 5, Type: EventWinRevertedType, Player: "Pepper", Reverts: 4, Time: at(32)},
+		{Seq: 6, Type: EventAttendanceRecordedType, Player: "Cleo", Time: at(40)},
+		{Seq: 7, Type: EventAttendanceRecordedType, Player: "Chris", Time: at(40)},
+		{Seq: 8, Type: EventWinRecordedType, Player: "Cleo", Time: at(70)},
+		{Seq: 9, Type: EventPlayerRenamedType, Player: "Chris", NewName: "Chris Jones", Time: at(71)},
+		{Seq: 10, Type: EventAttendanceRecordedType, Player: "Cleo", Time: at(80)},
+	}
+
+	got := GameHistory(events)
+
+	want := []GameRecord{
+		{Players: []string{"Cleo"}, Started: at(80)},
+		{Players: []string{"Cleo", "Chris Jones"}, Winner: "Cleo", Started: at(40), Finished: at(70)},
+		{Winner: "Pepper", Started: at(31), Finished: at(31), Undone: true},
+		{Players: []string{"Cleo", "Chris Jones"}, Winner: "Chris Jones", Started: at(0), Finished: at(30)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if wins := PlayerWins(events, "Chris Jones"); !reflect.DeepEqual(wins, []time.Time{at(30)}) {
+		t.Errorf("got Chris Jones's wins %v, want the one at 19:30", wins)
+	}
+	if wins := PlayerWins(events, "Pepper"); len(wins) != 0 {
+		t.Errorf("got Pepper's undone win back %v", wins)
+	}
+	if games := PlayerGames(events, "Cleo"); len(games) != 3 {
+		t.Errorf("got %d games for Cleo, want 3", len(games))
+	}
+}
@@ -0,0 +1,220 @@
+package poker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	HealthOK       = "ok"
+	HealthFailing  = "failing"
+	HealthDisabled = "disabled"
+
+	// healthCheckTimeout is how long a check can take before it counts as
+	// failing, so that a store stuck behind a lock shows up as one.
+	healthCheckTimeout = 2 * time.Second
+)
+
+var (
+	errHealthCheckTimedOut = errors.New("timed out")
+	errHealthCheckDisabled = errors.New("check not configured")
+)
+
+// HealthReport is what /healthz and /readyz return: how each check went,
+// and an overall Status that is only HealthOK if none of them failed.
+// WebSockets counts the sockets open on each route, and the spectators
+// watching, for information only.
+type HealthReport struct {
+	Status     string
+	Checks     map[string]HealthCheck
+	WebSockets map[string]int `json:",omitempty"`
+}
+
+type HealthCheck struct {
+	Status string
+	Error  string                 `json:",omitempty"`
+	Took   string                 `json:",omitempty"`
+	Detail map[string]interface{} `json:",omitempty"`
+}
+
+// StoreChecker is implemented by stores that keep their players somewhere
+// that can go wrong under them, such as a file, so /readyz can check on it.
+type StoreChecker interface {
+	CheckStore() error
+}
+
+type healthCheck struct {
+	name string
+	run  func() (map[string]interface{}, error)
+}
+
+// WithDataDir has /readyz check that dir, where the store keeps its files,
+// can still be written to.
+func WithDataDir(dir string) ServerOption {
+	return func(p *PlayerServer) {
+		p.dataDir = dir
+	}
+}
+
+// Health runs the checks that say whether the process is working at all,
+// which is that the game template renders.
+func (p *PlayerServer) Health() HealthReport {
+	return p.healthReport(p.liveChecks())
+}
+
+// Readiness runs every check, adding to Health whether the server is still
+// taking games, the store's files are still there to be read and the data
+// directory can be written to.
+func (p *PlayerServer) Readiness() HealthReport {
+	return p.healthReport(append(p.liveChecks(),
+		healthCheck{"accepting_games", p.checkAcceptingGames},
+		healthCheck{"store", p.checkStore},
+		healthCheck{"disk", p.checkDisk},
+	))
+}
+
+func (p *PlayerServer) liveChecks() []healthCheck {
+	return []healthCheck{
+		{"template", p.checkTemplate},
+	}
+}
+
+func (p *PlayerServer) healthReport(checks []healthCheck) HealthReport {
+	report := runHealthChecks(checks)
+	report.WebSockets = p.openConnections()
+	if p.broadcaster != nil {
+		report.WebSockets["watchers"] = p.broadcaster.Len()
+	}
+	return report
+}
+
+func (p *PlayerServer) healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, p.Health())
+}
+
+func (p *PlayerServer) readyHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, p.Readiness())
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("content-type", jsonContentType)
+	w.Header().Set("cache-control", "no-store")
+	if report.Status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func runHealthChecks(checks []healthCheck) HealthReport {
+	report := HealthReport{Status: HealthOK, Checks: map[string]HealthCheck{}}
+
+	for _, check := range checks {
+		start := time.Now()
+		detail, err := runWithTimeout(check.run)
+
+		result := HealthCheck{Status: HealthOK, Took: time.Since(start).String(), Detail: detail}
+		switch {
+		case errors.Is(err, errHealthCheckDisabled):
+			result = HealthCheck{Status: HealthDisabled}
+		case err != nil:
+			result.Status = HealthFailing
+			result.Error = err.Error()
+			report.Status = HealthFailing
+		}
+		report.Checks[check.name] = result
+	}
+	return report
+}
+
+func runWithTimeout(run func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	type result struct {
+		detail map[string]interface{}
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{nil, fmt.Errorf("panicked, %v", r)}
+			}
+		}()
+		detail, err := run()
+		done <- result{detail, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.detail, r.err
+	case <-time.After(healthCheckTimeout):
+		return nil, errHealthCheckTimedOut
+	}
+}
+
+func (p *PlayerServer) checkTemplate() (map[string]interface{}, error) {
+	tmpl, err := p.pageTemplates()
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.ExecuteTemplate(io.Discard, htmlTemplatePath, nil); err != nil {
+		return nil, fmt.Errorf("problem rendering the game page, %v", err)
+	}
+	return map[string]interface{}{"source": p.templateSource()}, nil
+}
+
+func (p *PlayerServer) templateSource() string {
+	switch {
+	case p.templatePath != "":
+		return p.templatePath
+	case p.reloadAssets:
+		return "asset directory"
+	}
+	return "built in"
+}
+
+func (p *PlayerServer) checkStore() (map[string]interface{}, error) {
+	if checker, ok := p.store.(StoreChecker); ok {
+		if err := checker.CheckStore(); err != nil {
+			return nil, err
+		}
+	}
+	league := p.store.GetLeague()
+	return map[string]interface{}{"players": len(league)}, nil
+}
+
+func (p *PlayerServer) checkDisk() (map[string]interface{}, error) {
+	if p.dataDir == "" {
+		return nil, errHealthCheckDisabled
+	}
+
+	probe, err := os.CreateTemp(p.dataDir, ".healthz-*")
+	if err != nil {
+		return nil, fmt.Errorf("problem writing to %s, %v", p.dataDir, err)
+	}
+	defer os.Remove(probe.Name())
+
+	_, err = probe.Write([]byte("ok"))
+	if closeErr := probe.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("problem writing to %s, %v", p.dataDir, err)
+	}
+	return map[string]interface{}{"dir": p.dataDir}, nil
+}
+
+// openConnections counts the WebSockets open on each route.
+func (p *PlayerServer) openConnections() map[string]int {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	open := make(map[string]int, len(p.connections))
+	for route, count := range p.connections {
+		open[route] = count
+	}
+	return open
+}
@@ -0,0 +1,101 @@
+package poker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	t.Run("healthz checks the template and counts the WebSockets", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithBroadcaster(NewBroadcaster()))
+
+		response, report := getHealthReport(t, server, "/healthz")
+
+		assertStatus(t, response, http.StatusOK)
+		assertContentType(t, response.Result().Header.Get("content-type"))
+		assertCheckStatus(t, report, "template", HealthOK)
+		if got, ok := report.WebSockets["watchers"]; !ok || got != 0 {
+			t.Errorf("got %v watchers, want 0", got)
+		}
+		if got, ok := report.WebSockets["/ws"]; !ok || got != 0 {
+			t.Errorf("got %v sockets on /ws, want 0", got)
+		}
+	})
+	t.Run("readyz checks the store and that the data directory is writable", func(t *testing.T) {
+		store := &StubPlayerStore{league: []Player{{"Cleo", 32, 40}}}
+		server, _ := NewPlayerServer(store, dummyGame, WithDataDir(t.TempDir()))
+
+		response, report := getHealthReport(t, server, "/readyz")
+
+		assertStatus(t, response, http.StatusOK)
+		if report.Status != HealthOK {
+			t.Errorf("got status %q, want ok: %+v", report.Status, report.Checks)
+		}
+		if got := report.Checks["store"].Detail["players"]; got != float64(1) {
+			t.Errorf("got %v players, want 1", got)
+		}
+		assertCheckStatus(t, report, "disk", HealthOK)
+	})
+	t.Run("readyz fails when the data directory can't be written to", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "gone")
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithDataDir(missing))
+
+		response, report := getHealthReport(t, server, "/readyz")
+
+		assertStatus(t, response, http.StatusServiceUnavailable)
+		assertCheckStatus(t, report, "disk", HealthFailing)
+		assertCheckStatus(t, report, "store", HealthOK)
+		if report.Checks["disk"].Error == "" {
+			t.Error("expected the disk check to say what went wrong")
+		}
+	})
+	t.Run("readyz fails when the store's file has gone", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "game.db.json")
+		store, closeFunc, err := FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeFunc()
+		server, _ := NewPlayerServer(NewLoggingPlayerStore(store, DiscardLogger), dummyGame, WithDataDir(dir))
+
+		assertNoError(t, os.Remove(path))
+		response, report := getHealthReport(t, server, "/readyz")
+
+		assertStatus(t, response, http.StatusServiceUnavailable)
+		assertCheckStatus(t, report, "store", HealthFailing)
+		if !strings.Contains(report.Checks["store"].Error, "game.db.json") {
+			t.Errorf("got store error %q, want it to name the missing file", report.Checks["store"].Error)
+		}
+	})
+	t.Run("readyz leaves out the disk check without a data directory", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame)
+
+		response, report := getHealthReport(t, server, "/readyz")
+
+		assertStatus(t, response, http.StatusOK)
+		assertCheckStatus(t, report, "disk", HealthDisabled)
+	})
+}
+
+func getHealthReport(t testing.TB, server http.Handler, path string) (*httptest.ResponseRecorder, HealthReport) {
+	t.Helper()
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report HealthReport
+	if err := json.NewDecoder(response.Body).Decode(&report); err != nil {
+		t.Fatalf("unable to parse health report %q, %v", response.Body, err)
+	}
+	return response, report
+}
+
+func assertCheckStatus(t testing.TB, report HealthReport, check, want string) {
+	t.Helper()
+	if got := report.Checks[check].Status; got != want {
+		t.Errorf("got %s check %q, want %q", check, got, want)
+	}
+}
@@ -0,0 +1,300 @@
+package poker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+
+	csvContentType = "text/csv"
+)
+
+var ErrInvalidImport = errors.New("the import has bad rows, nothing was imported")
+
+var leagueCSVHeader = []string{"name", "wins", "games"}
+var historyCSVHeader = []string{"seq", "time", "type", "player", "reverts", "new_name", "wins", "games"}
+
+// EventSource is implemented by stores that keep a history of what happened
+// to them, such as FileSystemPlayerStore.
+type EventSource interface {
+	Events() []Event
+}
+
+// PlayerImporter is implemented by stores that can take on a batch of
+// players' results in one go instead of a win at a time.
+type PlayerImporter interface {
+	ImportPlayers(players []Player) error
+}
+
+// LeagueExport is what gets written out for the JSON format.
+type LeagueExport struct {
+	League  League
+	History []Event `json:",omitempty"`
+}
+
+// ImportProblem is a row that couldn't be imported. Rows count from 1, not
+// including a CSV header.
+type ImportProblem struct {
+	Row    int
+	Reason string
+}
+
+// ImportReport says what an import did, or would have done on a dry run.
+type ImportReport struct {
+	DryRun   bool
+	Players  []Player
+	Problems []ImportProblem `json:",omitempty"`
+}
+
+// ExportLeague writes the league, and in JSON the store's history if it has
+// one, in format.
+func ExportLeague(w io.Writer, store PlayerStore, format string) error {
+	switch format {
+	case FormatCSV:
+		return writeLeagueCSV(w, store.GetLeague())
+	case FormatJSON:
+		export := LeagueExport{League: store.GetLeague()}
+		if source, ok := store.(EventSource); ok {
+			export.History = source.Events()
+		}
+		return json.NewEncoder(w).Encode(export)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// ExportHistory writes the store's history as CSV, one event per row apart
+// from imports, which get a row for each player they added to. Wrapped
+// stores are EventSources whatever they wrap, so a store without a history
+// is one whose Events are nil.
+func ExportHistory(w io.Writer, store PlayerStore) error {
+	var events []Event
+	if source, ok := store.(EventSource); ok {
+		events = source.Events()
+	}
+	if events == nil {
+		return errors.New("this store does not keep a history")
+	}
+
+	out := csv.NewWriter(w)
+	out.Write(historyCSVHeader)
+	for _, event := range events {
+		if event.Type == EventSnapshotType {
+			continue
+		}
+		row := []string{strconv.Itoa(event.Seq), event.Time.Format(time.RFC3339), event.Type, csvText(event.Player), "", csvText(event.NewName), "", ""}
+		if event.Reverts != 0 {
+			row[4] = strconv.Itoa(event.Reverts)
+		}
+		if event.Type != EventPlayersImportedType {
+			out.Write(row)
+			continue
+		}
+		for _, player := range event.League {
+			row[3], row[6], row[7] = csvText(player.Name), strconv.Itoa(player.Wins), strconv.Itoa(player.Games)
+			out.Write(row)
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeLeagueCSV(w io.Writer, league League) error {
+	out := csv.NewWriter(w)
+	out.Write(leagueCSVHeader)
+	for _, player := range league {
+		out.Write([]string{csvText(player.Name), strconv.Itoa(player.Wins), strconv.Itoa(player.Games)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvText makes s safe to open in a spreadsheet, which would take text
+// starting with one of these characters as a formula, by putting a quote in
+// front of it. Anyone can choose a player's name, so names can't be trusted
+// not to be formulas.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// fromCSVText undoes csvText, so exported leagues import as they were.
+func fromCSVText(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// ImportLeague reads players' results from r in format and adds them to
+// the store. Nothing is imported if any row is bad, or on a dry run; either
+// way the report lists what would have been. JSON can be a list of players
+// or an earlier export, whose history is left out as the store makes its own.
+func ImportLeague(store PlayerStore, r io.Reader, format string, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		report.Players, report.Problems, err = parseLeagueCSV(r)
+	case FormatJSON:
+		report.Players, report.Problems, err = parseLeagueJSON(r)
+	default:
+		err = fmt.Errorf("unknown import format %q", format)
+	}
+
+	if err != nil {
+		return report, err
+	}
+	if len(report.Problems) > 0 {
+		return report, ErrInvalidImport
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	return report, ImportPlayers(store, report.Players)
+}
+
+// ImportPlayers adds players' wins and games to store, in one batch if it
+// supports that.
+func ImportPlayers(store PlayerStore, players []Player) error {
+	if importer, ok := store.(PlayerImporter); ok {
+		return importer.ImportPlayers(players)
+	}
+
+	for _, player := range players {
+		for i := 0; i < player.Games; i++ {
+			store.RecordAttendance(player.Name)
+		}
+		for i := 0; i < player.Wins; i++ {
+			store.RecordWin(player.Name)
+		}
+	}
+	return nil
+}
+
+func parseLeagueCSV(r io.Reader) ([]Player, []ImportProblem, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.TrimLeadingSpace = true
+
+	rows, err := in.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem reading CSV, %v", err)
+	}
+	if len(rows) == 0 || !isLeagueCSVHeader(rows[0]) {
+		return nil, nil, fmt.Errorf("CSV must start with the header %s", strings.Join(leagueCSVHeader, ","))
+	}
+
+	var players []Player
+	var problems []ImportProblem
+	seen := map[string]bool{}
+
+	for i, row := range rows[1:] {
+		player, err := parseLeagueRow(row)
+		if err == nil {
+			err = checkImportedPlayer(player, seen)
+		}
+		if err != nil {
+			problems = append(problems, ImportProblem{Row: i + 1, Reason: err.Error()})
+			continue
+		}
+		players = append(players, player)
+	}
+	return players, problems, nil
+}
+
+func isLeagueCSVHeader(row []string) bool {
+	if len(row) < 2 || len(row) > len(leagueCSVHeader) {
+		return false
+	}
+	for i, field := range row {
+		if !strings.EqualFold(strings.TrimSpace(field), leagueCSVHeader[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseLeagueRow(row []string) (Player, error) {
+	if len(row) < 2 || len(row) > len(leagueCSVHeader) {
+		return Player{}, fmt.Errorf("want %d or %d fields, got %d", len(leagueCSVHeader)-1, len(leagueCSVHeader), len(row))
+	}
+
+	player := Player{Name: fromCSVText(strings.TrimSpace(row[0]))}
+	var err error
+	if player.Wins, err = parseCount("wins", row[1]); err != nil {
+		return Player{}, err
+	}
+	if len(row) == len(leagueCSVHeader) {
+		if player.Games, err = parseCount("games", row[2]); err != nil {
+			return Player{}, err
+		}
+	}
+	return player, nil
+}
+
+func parseCount(field, value string) (int, error) {
+	count, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("%s must be a whole number of at least 0, got %q", field, value)
+	}
+	return count, nil
+}
+
+func parseLeagueJSON(r io.Reader) ([]Player, []ImportProblem, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem reading JSON, %v", err)
+	}
+
+	var league League
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var export LeagueExport
+		err = json.Unmarshal(data, &export)
+		league = export.League
+	} else {
+		err = json.Unmarshal(data, &league)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem parsing JSON, %v", err)
+	}
+
+	var players []Player
+	var problems []ImportProblem
+	seen := map[string]bool{}
+
+	for i, player := range league {
+		player.Name = strings.TrimSpace(player.Name)
+		if err := checkImportedPlayer(player, seen); err != nil {
+			problems = append(problems, ImportProblem{Row: i + 1, Reason: err.Error()})
+			continue
+		}
+		players = append(players, player)
+	}
+	return players, problems, nil
+}
+
+func checkImportedPlayer(player Player, seen map[string]bool) error {
+	switch {
+	case player.Name == "":
+		return errors.New("name is missing")
+	case seen[strings.ToLower(player.Name)]:
+		return fmt.Errorf("%s is listed more than once", player.Name)
+	case player.Wins < 0 || player.Games < 0:
+		return errors.New("wins and games can't be negative")
+	}
+	seen[strings.ToLower(player.Name)] = true
+	return nil
+}
@@ -0,0 +1,134 @@
+package poker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportLeague(t *testing.T) {
+	store := &StubPlayerStore{league: []Player{
+		{Name: "Chris", Wins: 3, Games: 5},
+		{Name: "Cleo, Jr", Wins: 1, Games: 2},
+	}}
+
+	t.Run("writes the league as CSV", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assertNoError(t, ExportLeague(out, store, FormatCSV))
+
+		assertResponseBody(t, out.String(), "name,wins,games\nChris,3,5\n\"Cleo, Jr\",1,2\n")
+	})
+	t.Run("keeps names that look like formulas from being run by spreadsheets", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, "")
+		defer cleanDatabase()
+		eventLog, cleanEventLog := createTempFile(t, "")
+		defer cleanEventLog()
+		fileStore, err := NewFileSystemPlayerStoreWithLog(database, eventLog)
+		assertNoError(t, err)
+		fileStore.RecordWin(`=HYPERLINK("http://evil.example","Paul")`)
+		fileStore.RecordWin("@Cleo")
+
+		league := &bytes.Buffer{}
+		assertNoError(t, ExportLeague(league, fileStore, FormatCSV))
+		history := &bytes.Buffer{}
+		assertNoError(t, ExportHistory(history, fileStore))
+
+		for _, out := range []string{league.String(), history.String()} {
+			if !strings.Contains(out, `"'=HYPERLINK(""http://evil.example"",""Paul"")"`) || !strings.Contains(out, "'@Cleo,") {
+				t.Errorf("got export %s, want the names quoted", out)
+			}
+		}
+
+		players, problems, err := parseLeagueCSV(league)
+		assertNoError(t, err)
+		if len(problems) != 0 || len(players) != 2 || players[1].Name != "@Cleo" {
+			t.Errorf("got %+v %v reading the export back, want the names as they were", players, problems)
+		}
+	})
+	t.Run("writes the league and its history as JSON", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, "")
+		defer cleanDatabase()
+		fileStore, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+		fileStore.RecordWin("Paul")
+
+		out := &bytes.Buffer{}
+		assertNoError(t, ExportLeague(out, fileStore, FormatJSON))
+
+		got := out.String()
+		if !strings.Contains(got, `"League":[{"Name":"Paul","Wins":1,"Games":0}]`) || !strings.Contains(got, `"Type":"WinRecorded"`) {
+			t.Errorf("got export %s", got)
+		}
+	})
+	t.Run("refuses to export a history a wrapped store doesn't keep", func(t *testing.T) {
+		wrapped := NewWebhookPlayerStore(store, NewWebhooks(1, time.Millisecond))
+
+		err := ExportHistory(&bytes.Buffer{}, wrapped)
+
+		if err == nil || !strings.Contains(err.Error(), "does not keep a history") {
+			t.Errorf("got %v, want an error saying there is no history", err)
+		}
+	})
+}
+
+func TestImportLeague(t *testing.T) {
+	t.Run("adds results from CSV to the league", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[{"Name": "Paul", "Wins": 2, "Games": 4}]`)
+		defer cleanDatabase()
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		in := strings.NewReader("Name,Wins,Games\nPaul,1,3\nRand, 4, 6\n")
+		_, err = ImportLeague(store, in, FormatCSV, false)
+		assertNoError(t, err)
+
+		assertLeague(t, store.GetLeague(), []Player{
+			{Name: "Rand", Wins: 4, Games: 6},
+			{Name: "Paul", Wins: 3, Games: 7},
+		})
+	})
+	t.Run("reports bad rows and imports nothing", func(t *testing.T) {
+		store := &StubPlayerStore{}
+		in := strings.NewReader("name,wins,games\nPaul,1,3\n,2,2\nRand,lots,1\npaul,1,1\nCleo\n")
+
+		report, err := ImportLeague(store, in, FormatCSV, false)
+
+		if err != ErrInvalidImport {
+			t.Errorf("got error %v, want %v", err, ErrInvalidImport)
+		}
+		rows := []int{}
+		for _, problem := range report.Problems {
+			rows = append(rows, problem.Row)
+		}
+		if len(rows) != 4 || rows[0] != 2 || rows[3] != 5 {
+			t.Errorf("got problems %+v", report.Problems)
+		}
+		if len(store.winCalls) != 0 {
+			t.Errorf("expected nothing imported, got wins for %v", store.winCalls)
+		}
+	})
+	t.Run("only validates on a dry run", func(t *testing.T) {
+		store := &StubPlayerStore{}
+		in := strings.NewReader(`{"League": [{"Name": "Paul", "Wins": 2, "Games": 2}]}`)
+
+		report, err := ImportLeague(store, in, FormatJSON, true)
+		assertNoError(t, err)
+
+		if !report.DryRun || len(report.Players) != 1 {
+			t.Errorf("got report %+v", report)
+		}
+		if len(store.winCalls) != 0 || len(store.attendanceCalls) != 0 {
+			t.Error("a dry run should not change the store")
+		}
+	})
+	t.Run("records wins one at a time on stores without batch imports", func(t *testing.T) {
+		store := &StubPlayerStore{}
+
+		_, err := ImportLeague(store, strings.NewReader(`[{"Name": "Paul", "Wins": 1, "Games": 2}]`), FormatJSON, false)
+		assertNoError(t, err)
+
+		AssertPlayerWin(t, store, "Paul")
+		AssertAttendance(t, store, "Paul", "Paul")
+	})
+}
@@ -0,0 +1,108 @@
+package poker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// InMemoryPlayerStore keeps the league in memory only, so it is gone when
+// the program stops. It is safe to share between goroutines and keeps the
+// same history as FileSystemPlayerStore, so wins can be undone.
+type InMemoryPlayerStore struct {
+	mu     sync.Mutex
+	league League
+	events *EventLog
+}
+
+func NewInMemoryPlayerStore() *InMemoryPlayerStore {
+	events, _ := NewEventLog(nil)
+	return &InMemoryPlayerStore{league: League{}, events: events}
+}
+
+func (i *InMemoryPlayerStore) GetPlayerScore(name string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if player := i.league.Find(name); player != nil {
+		return player.Wins
+	}
+	return 0
+}
+
+func (i *InMemoryPlayerStore) GetLeague() League {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	league := append(League{}, i.league...)
+	sort.SliceStable(league, func(a, b int) bool {
+		return league[a].Wins > league[b].Wins
+	})
+	return league
+}
+
+func (i *InMemoryPlayerStore) RecordWin(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.register(name)
+	i.record(Event{Type: EventWinRecordedType, Player: name})
+}
+
+func (i *InMemoryPlayerStore) RecordAttendance(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.register(name)
+	i.record(Event{Type: EventAttendanceRecordedType, Player: name})
+}
+
+func (i *InMemoryPlayerStore) UndoLastWin() (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	win, ok := i.events.LastUndoableWin()
+	if !ok {
+		return "", ErrNothingToUndo
+	}
+	i.record(Event{Type: EventWinRevertedType, Player: win.Player, Reverts: win.Seq})
+	return win.Player, nil
+}
+
+func (i *InMemoryPlayerStore) RenamePlayer(from, to string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.league.Find(from) == nil {
+		return fmt.Errorf("could not rename %s, %w", from, ErrPlayerNotFound)
+	}
+	if i.league.Find(to) != nil {
+		return fmt.Errorf("could not rename %s to %s, %w", from, to, ErrPlayerExists)
+	}
+	i.record(Event{Type: EventPlayerRenamedType, Player: from, NewName: to})
+	return nil
+}
+
+func (i *InMemoryPlayerStore) ImportPlayers(players []Player) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.record(Event{Type: EventPlayersImportedType, League: append(League{}, players...)})
+	return nil
+}
+
+func (i *InMemoryPlayerStore) Events() []Event {
+	return i.events.Events()
+}
+
+func (i *InMemoryPlayerStore) register(name string) {
+	if i.league.Find(name) == nil {
+		i.record(Event{Type: EventPlayerRegisteredType, Player: name})
+	}
+}
+
+func (i *InMemoryPlayerStore) record(event Event) {
+	// Appending to an in-memory log can't fail.
+	i.events.Append(event)
+	i.league = i.league.Apply(event)
+}
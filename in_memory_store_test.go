@@ -0,0 +1,38 @@
+package poker
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestInMemoryPlayerStore(t *testing.T) {
+	t.Run("records wins from many goroutines", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store.RecordWin("Paul")
+				store.GetLeague()
+			}()
+		}
+		wg.Wait()
+
+		assertPlayerScore(t, store.GetPlayerScore("Paul"), 50)
+	})
+	t.Run("keeps a history it can undo and rename through", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Paul")
+		store.RecordWin("Rand")
+		assertNoError(t, store.RenamePlayer("Rand", "Randy"))
+
+		undone, err := store.UndoLastWin()
+		assertNoError(t, err)
+		if undone != "Randy" {
+			t.Errorf("undid a win for %q, want Randy", undone)
+		}
+		assertLeague(t, store.GetLeague(), []Player{{Name: "Paul", Wins: 1}, {Name: "Randy"}})
+	})
+}
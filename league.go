@@ -1,7 +1,9 @@
 package poker
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -17,11 +19,134 @@ func (l League) Find(name string) *Player {
 	return nil
 }
 
+// Apply returns the league as it stands after event.
+func (l League) Apply(event Event) League {
+	if event.Type == EventSnapshotType {
+		return append(League{}, event.League...)
+	}
+	if event.Type == EventPlayersImportedType {
+		return l.add(event.League)
+	}
+
+	player := l.Find(event.Player)
+	if player == nil {
+		switch event.Type {
+		case EventPlayerRegisteredType, EventWinRecordedType, EventAttendanceRecordedType:
+			l = append(l, Player{Name: event.Player})
+			player = &l[len(l)-1]
+		default:
+			return l
+		}
+	}
+
+	switch event.Type {
+	case EventWinRecordedType:
+		player.Wins += 1
+	case EventWinRevertedType:
+		if player.Wins > 0 {
+			player.Wins -= 1
+		}
+	case EventAttendanceRecordedType:
+		player.Games += 1
+	case EventPlayerRenamedType:
+		player.Name = event.NewName
+	}
+	return l
+}
+
+func (l League) add(players League) League {
+	for _, imported := range players {
+		player := l.Find(imported.Name)
+		if player == nil {
+			l = append(l, Player{Name: imported.Name})
+			player = &l[len(l)-1]
+		}
+		player.Wins += imported.Wins
+		player.Games += imported.Games
+	}
+	return l
+}
+
+// LeagueSchemaVersion is the version of the player database format this
+// build writes. Version 1 was a bare JSON array of players.
+const LeagueSchemaVersion = 2
+
+var ErrNewerSchemaVersion = errors.New("the league was written by a newer version of this program")
+
+// LeagueFile is the versioned envelope the league is saved in.
+type LeagueFile struct {
+	Version int
+	League  League
+}
+
+// leagueMigrations[v] brings a version v file up to version v+1.
+var leagueMigrations = map[int]func(LeagueFile) LeagueFile{
+	1: func(file LeagueFile) LeagueFile {
+		file.Version = 2
+		return file
+	},
+}
+
 func NewLeague(rdr io.Reader) ([]Player, error) {
-	var league []Player
-	err := json.NewDecoder(rdr).Decode(&league)
+	file, _, err := ReadLeagueFile(rdr)
+	return file.League, err
+}
+
+// ReadLeagueFile decodes a league saved in any schema version up to
+// LeagueSchemaVersion, migrating it to the current one and saying whether
+// it had to. Files that can't be parsed give an error wrapping
+// ErrCorruptLeague that says where the problem is.
+func ReadLeagueFile(rdr io.Reader) (LeagueFile, bool, error) {
+	data, err := io.ReadAll(rdr)
+	if err != nil {
+		return LeagueFile{}, false, fmt.Errorf("problem reading league, %v", err)
+	}
+
+	file, problem := parseLeagueFile(data)
+	if problem != nil {
+		return LeagueFile{}, false, fmt.Errorf("problem parsing league at %v, %w", problem, ErrCorruptLeague)
+	}
+
+	if file.Version > LeagueSchemaVersion {
+		return LeagueFile{}, false, fmt.Errorf("league is schema version %d but this build only reads up to %d, %w", file.Version, LeagueSchemaVersion, ErrNewerSchemaVersion)
+	}
+
+	migrated := file.Version < LeagueSchemaVersion
+	for file.Version < LeagueSchemaVersion {
+		file = leagueMigrations[file.Version](file)
+	}
+	if file.League == nil {
+		file.League = League{}
+	}
+	return file, migrated, nil
+}
+
+func parseLeagueFile(data []byte) (LeagueFile, *LeagueProblem) {
+	start := int64(len(data) - len(bytes.TrimLeft(data, jsonWhitespace)))
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return LeagueFile{}, problemFromJSONError(data, 0, err)
+	}
+	if rest := bytes.TrimLeft(data[decoder.InputOffset():], jsonWhitespace); len(rest) > 0 {
+		return LeagueFile{}, newLeagueProblem(data, int64(len(data)-len(rest)), "unexpected data after the league")
+	}
+
+	var file LeagueFile
+	var err error
+	if raw[0] == '[' {
+		file.Version = 1
+		err = json.Unmarshal(raw, &file.League)
+	} else {
+		err = json.Unmarshal(raw, &file)
+	}
 	if err != nil {
-		err = fmt.Errorf("problem parsing league, %v", err)
+		return LeagueFile{}, problemFromJSONError(data, start, err)
+	}
+
+	if file.Version < 1 {
+		return LeagueFile{}, newLeagueProblem(data, start, fmt.Sprintf("unknown schema version %d", file.Version))
 	}
-	return league, err
+	return file, nil
 }
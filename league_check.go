@@ -0,0 +1,287 @@
+package poker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const jsonWhitespace = " \t\r\n"
+
+var ErrCorruptLeague = errors.New("the league file is corrupt")
+
+// LeagueProblem is something wrong at a particular point in a league file.
+// Offset counts bytes from the start of the file, Line and Column from 1.
+type LeagueProblem struct {
+	Offset int64
+	Line   int
+	Column int
+	Reason string
+}
+
+func (p LeagueProblem) String() string {
+	return fmt.Sprintf("line %d column %d (byte %d): %s", p.Line, p.Column, p.Offset, p.Reason)
+}
+
+func newLeagueProblem(data []byte, offset int64, reason string) *LeagueProblem {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return &LeagueProblem{Offset: offset, Line: line, Column: column, Reason: reason}
+}
+
+func problemFromJSONError(data []byte, start int64, err error) *LeagueProblem {
+	var syntax *json.SyntaxError
+	var wrongType *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntax):
+		// Offset is just past the byte that broke the syntax.
+		offset := start + syntax.Offset
+		if offset > 0 {
+			offset--
+		}
+		return newLeagueProblem(data, offset, syntax.Error())
+	case errors.As(err, &wrongType):
+		return newLeagueProblem(data, start+wrongType.Offset, fmt.Sprintf("%s can't be a %s", wrongType.Field, wrongType.Value))
+	case err == io.EOF:
+		return newLeagueProblem(data, 0, "the file is empty")
+	case err == io.ErrUnexpectedEOF:
+		return newLeagueProblem(data, int64(len(data)), "the file ends part way through the league")
+	}
+	return newLeagueProblem(data, start, err.Error())
+}
+
+// ValidateLeague checks a league file, returning every problem it finds
+// with where it is: the first syntax error if it isn't JSON at all,
+// otherwise each bad player record.
+func ValidateLeague(data []byte) []LeagueProblem {
+	file, problem := parseLeagueFile(data)
+	if problem != nil && !json.Valid(data) {
+		return []LeagueProblem{*problem}
+	}
+	if problem == nil && file.Version > LeagueSchemaVersion {
+		return []LeagueProblem{*newLeagueProblem(data, 0, fmt.Sprintf("schema version %d is newer than this build's %d", file.Version, LeagueSchemaVersion))}
+	}
+
+	var problems []LeagueProblem
+	seen := map[string]bool{}
+	for _, record := range playerRecords(data) {
+		var player Player
+		if err := json.Unmarshal(record.raw, &player); err != nil {
+			problems = append(problems, *problemFromJSONError(data, record.offset, err))
+			continue
+		}
+		if err := checkImportedPlayer(player, seen); err != nil {
+			problems = append(problems, *newLeagueProblem(data, record.offset, err.Error()))
+		}
+	}
+	if problem != nil && len(problems) == 0 {
+		problems = append(problems, *problem)
+	}
+	return problems
+}
+
+// playerRecords finds each player in a league file that parses.
+func playerRecords(data []byte) []leagueRecord {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		if token != json.Delim('{') || !findLeague(decoder) {
+			return nil
+		}
+	}
+
+	var records []leagueRecord
+	for decoder.More() {
+		offset := decoder.InputOffset()
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			break
+		}
+		skipped := bytes.TrimLeft(data[offset:], jsonWhitespace+",")
+		records = append(records, leagueRecord{offset: int64(len(data) - len(skipped)), raw: raw})
+	}
+	return records
+}
+
+// findLeague moves decoder, just inside the envelope, on to the start of
+// the League list.
+func findLeague(decoder *json.Decoder) bool {
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if key == "League" {
+			token, err := decoder.Token()
+			return err == nil && token == json.Delim('[')
+		}
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+type leagueRecord struct {
+	offset int64
+	raw    []byte
+}
+
+// leagueRecords finds the innermost JSON objects in data, which in a league
+// file are the player records, without needing the file to parse. Anything
+// cut off part way through is left out.
+func leagueRecords(data []byte) []leagueRecord {
+	var records []leagueRecord
+	start := -1
+	inString, escaped := false, false
+
+	for i, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			start = i
+		case c == '}' && start >= 0:
+			records = append(records, leagueRecord{offset: int64(start), raw: data[start : i+1]})
+			start = -1
+		}
+	}
+	return records
+}
+
+// RepairLeague salvages every player record in data it can read, even if
+// the file as a whole doesn't parse, and reports the ones it had to skip.
+func RepairLeague(data []byte) (League, []LeagueProblem) {
+	league := League{}
+	var skipped []LeagueProblem
+	seen := map[string]bool{}
+
+	for _, record := range leagueRecords(data) {
+		var player Player
+		if err := json.Unmarshal(record.raw, &player); err != nil {
+			skipped = append(skipped, *problemFromJSONError(data, record.offset, err))
+			continue
+		}
+		if strings.TrimSpace(player.Name) == "" && player.Wins == 0 && player.Games == 0 {
+			continue
+		}
+		if err := checkImportedPlayer(player, seen); err != nil {
+			skipped = append(skipped, *newLeagueProblem(data, record.offset, err.Error()))
+			continue
+		}
+		league = append(league, player)
+	}
+	return league, skipped
+}
+
+// RepairReport says how RepairLeagueFile put a league file back together.
+type RepairReport struct {
+	League      League
+	Skipped     []LeagueProblem
+	FromLog     bool
+	Quarantined []string
+}
+
+// RepairLeagueFile rewrites the league file at path from whatever can be
+// saved. If its event log can be replayed the league comes from there,
+// otherwise from the records RepairLeague salvages, and the unusable log
+// is quarantined so it isn't replayed over the repair. The original file is
+// always kept alongside under a .corrupt name.
+func RepairLeagueFile(path string, now time.Time) (RepairReport, error) {
+	var report RepairReport
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("problem reading %s, %v", path, err)
+	}
+
+	events, logErr := readEventLogFile(path + eventLogSuffix)
+	if logErr == nil && lastSnapshot(events) >= 0 {
+		report.League = ReplayEvents(events)
+		report.FromLog = true
+	} else {
+		report.League, report.Skipped = RepairLeague(data)
+	}
+
+	quarantine := []string{path}
+	if !report.FromLog {
+		quarantine = append(quarantine, path+eventLogSuffix)
+	}
+	if report.Quarantined, err = quarantineFiles(now, quarantine...); err != nil {
+		return report, err
+	}
+
+	repaired, err := json.Marshal(LeagueFile{Version: LeagueSchemaVersion, League: report.League})
+	if err != nil {
+		return report, fmt.Errorf("problem encoding repaired league, %v", err)
+	}
+	if err := os.WriteFile(path, repaired, 0666); err != nil {
+		return report, fmt.Errorf("problem writing repaired league to %s, %v", path, err)
+	}
+	return report, nil
+}
+
+func readEventLogFile(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events, err := NewEventLog(file)
+	if err != nil {
+		return nil, err
+	}
+	return events.Events(), nil
+}
+
+// QuarantineLeague moves the league file at path and its event log aside,
+// then restores the newest backup in backupDir in their place. It returns
+// the backup it used.
+func QuarantineLeague(path, backupDir string, now time.Time) (string, error) {
+	backups, err := ListBackups(backupDir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("no backups in %s to start from", backupDir)
+	}
+	latest := backups[len(backups)-1]
+
+	if _, err := quarantineFiles(now, path, path+eventLogSuffix, path+eventArchiveSuffix); err != nil {
+		return "", err
+	}
+	return latest, RestoreBackup(latest, path)
+}
+
+func quarantineFiles(now time.Time, paths ...string) ([]string, error) {
+	var moved []string
+	for _, path := range paths {
+		aside := path + ".corrupt-" + now.UTC().Format(backupTimeFormat)
+		err := os.Rename(path, aside)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return moved, fmt.Errorf("problem moving %s aside, %v", path, err)
+		}
+		moved = append(moved, aside)
+	}
+	return moved, nil
+}
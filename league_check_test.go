@@ -0,0 +1,119 @@
+package poker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateLeague(t *testing.T) {
+	t.Run("pinpoints where the file stops parsing", func(t *testing.T) {
+		data := []byte("{\"Version\": 2, \"League\": [\n  {\"Name\": \"Paul\", \"Wins\": 1},\n  {\"Name\": \"Rand\" \"Wins\": 2}]}")
+
+		problems := ValidateLeague(data)
+
+		if len(problems) != 1 || problems[0].Line != 3 || problems[0].Column != 19 {
+			t.Errorf("got problems %v, want one on line 3 column 19", problems)
+		}
+	})
+	t.Run("reports bad player records", func(t *testing.T) {
+		data := []byte(`[{"Name": "Paul", "Wins": 1}, {"Name": "", "Wins": 2}, {"Name": "Cleo", "Wins": "lots"}]`)
+
+		problems := ValidateLeague(data)
+
+		if len(problems) != 2 || problems[0].Offset != 30 || !strings.Contains(problems[1].Reason, "Wins") {
+			t.Errorf("got problems %v", problems)
+		}
+	})
+	t.Run("finds nothing wrong with a good file", func(t *testing.T) {
+		if problems := ValidateLeague([]byte(`{"Version": 2, "League": []}`)); len(problems) != 0 {
+			t.Errorf("got problems %v", problems)
+		}
+	})
+	t.Run("stores refuse corrupt files", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[{"Name": "Paul", "Wi`)
+		defer cleanDatabase()
+
+		_, err := NewFileSystemPlayerStore(database)
+
+		if !errors.Is(err, ErrCorruptLeague) {
+			t.Errorf("got %v, want %v", err, ErrCorruptLeague)
+		}
+	})
+}
+
+func TestRepairLeague(t *testing.T) {
+	t.Run("salvages the records it can read", func(t *testing.T) {
+		data := []byte(`{"Version": 2, "League": [{"Name": "Paul", "Wins": 3}, {"Name": "Rand", "Wins": -1}, {"Name": "Cleo {the}", "Wins": 1}, {"Name": "Chr`)
+
+		league, skipped := RepairLeague(data)
+
+		assertLeague(t, league, []Player{{Name: "Paul", Wins: 3}, {Name: "Cleo {the}", Wins: 1}})
+		if len(skipped) != 1 {
+			t.Errorf("got skipped %v, want Rand's record", skipped)
+		}
+	})
+	t.Run("rebuilds the file and moves the original aside", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "game.db.json")
+		os.WriteFile(path, []byte(`[{"Name": "Paul", "Wins": 3}, {"Na`), 0666)
+
+		report, err := RepairLeagueFile(path, time.Now())
+		assertNoError(t, err)
+
+		if len(report.Quarantined) != 1 {
+			t.Errorf("got quarantined %v, want the original", report.Quarantined)
+		}
+		store, closeFunc, err := FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeFunc()
+		assertLeague(t, store.GetLeague(), []Player{{Name: "Paul", Wins: 3}})
+	})
+	t.Run("starts from the latest backup", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		store.RecordWin("Paul")
+		_, err := WriteBackup(store, filepath.Join(dir, "backups"), 0, time.Now())
+		assertNoError(t, err)
+
+		path := filepath.Join(dir, "game.db.json")
+		os.WriteFile(path, []byte("not json"), 0666)
+
+		_, err = QuarantineLeague(path, filepath.Join(dir, "backups"), time.Now())
+		assertNoError(t, err)
+
+		restored, closeFunc, err := FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeFunc()
+		assertPlayerScore(t, restored.GetPlayerScore("Paul"), 1)
+	})
+	t.Run("starts from the latest backup when the event log is cut short", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newBackedUpStore(t, dir)
+		store.RecordWin("Paul")
+		_, err := WriteBackup(store, filepath.Join(dir, "backups"), 0, time.Now())
+		assertNoError(t, err)
+
+		path := filepath.Join(dir, "game.db.json")
+		eventLog, err := os.OpenFile(path+eventLogSuffix, os.O_WRONLY|os.O_APPEND, 0666)
+		assertNoError(t, err)
+		eventLog.WriteString(`{"Seq": 2, "Ty`)
+		eventLog.Close()
+
+		_, _, err = FileSystemStoreFromFile(path)
+		if !errors.Is(err, ErrCorruptLeague) {
+			t.Fatalf("got %v, want %v", err, ErrCorruptLeague)
+		}
+
+		_, err = QuarantineLeague(path, filepath.Join(dir, "backups"), time.Now())
+		assertNoError(t, err)
+
+		restored, closeFunc, err := FileSystemStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeFunc()
+		assertPlayerScore(t, restored.GetPlayerScore("Paul"), 1)
+	})
+}
@@ -0,0 +1,258 @@
+package poker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l LogLevel) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return logLevelNames[l]
+}
+
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return LogLevel(level), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, want one of %s", name, strings.Join(logLevelNames, ", "))
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Logger writes levelled log lines with key value fields, either as
+// key=value text or one JSON object per line. Loggers made by With share
+// their parent's output.
+type Logger struct {
+	out    *syncWriter
+	clock  Clock
+	level  LogLevel
+	json   bool
+	fields []interface{}
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// DefaultLogger is used wherever no other Logger has been given.
+var DefaultLogger = NewLogger(os.Stderr, LevelInfo, LogFormatText)
+
+// DiscardLogger throws everything away.
+var DiscardLogger = NewLogger(io.Discard, LevelError+1, LogFormatText)
+
+func NewLogger(out io.Writer, level LogLevel, format string) *Logger {
+	return NewLoggerWithClock(SystemClock, out, level, format)
+}
+
+func NewLoggerWithClock(clock Clock, out io.Writer, level LogLevel, format string) *Logger {
+	return &Logger{
+		out:   &syncWriter{w: out},
+		clock: clock,
+		level: level,
+		json:  format == LogFormatJSON,
+	}
+}
+
+// With returns a logger that adds keyvals, pairs of keys and values, to
+// every line.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	child := *l
+	child.fields = append(append([]interface{}{}, l.fields...), keyvals...)
+	return &child
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+func (l *Logger) Info(msg string, keyvals ...interface{})  { l.log(LevelInfo, msg, keyvals) }
+func (l *Logger) Warn(msg string, keyvals ...interface{})  { l.log(LevelWarn, msg, keyvals) }
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+func (l *Logger) log(level LogLevel, msg string, keyvals []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	fields := append(append([]interface{}{
+		"time", l.clock.Now().UTC().Format(time.RFC3339Nano),
+		"level", level.String(),
+		"msg", msg,
+	}, l.fields...), keyvals...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, "(missing)")
+	}
+
+	var line []byte
+	if l.json {
+		line = jsonLogLine(fields)
+	} else {
+		line = textLogLine(fields)
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(line)
+}
+
+func textLogLine(fields []interface{}) []byte {
+	var line strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+		value := logValue(fields[i+1])
+		if text, ok := value.(string); ok {
+			if strings.ContainsAny(text, " =\"\n\t") || text == "" {
+				text = strconv.Quote(text)
+			}
+			value = text
+		}
+		fmt.Fprintf(&line, "%v=%v", fields[i], value)
+	}
+	line.WriteByte('\n')
+	return []byte(line.String())
+}
+
+func jsonLogLine(fields []interface{}) []byte {
+	var line strings.Builder
+	line.WriteByte('{')
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(fields[i]))
+		value, err := json.Marshal(logValue(fields[i+1]))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(fields[i+1]))
+		}
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+	return []byte(line.String())
+}
+
+// logValue turns values that don't read well as they are, like errors and
+// durations, into strings.
+func logValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+// NewLogID makes a short random ID for telling requests and games apart in
+// the logs.
+func NewLogID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(id)
+}
+
+type loggerKey struct{}
+
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFrom returns the logger carried by ctx, or DefaultLogger.
+func LoggerFrom(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return logger
+	}
+	return DefaultLogger
+}
+
+// LoggingGame is implemented by games that log what happens in them, so
+// they can be given a logger that identifies each game.
+type LoggingGame interface {
+	SetLogger(logger *Logger)
+}
+
+type loggingPlayerStore struct {
+	PlayerStore
+	wrappedStore
+	logger *Logger
+}
+
+// NewLoggingPlayerStore wraps store so that each operation is logged at
+// debug level, and failures as warnings, with logger's fields.
+func NewLoggingPlayerStore(store PlayerStore, logger *Logger) PlayerStore {
+	return &loggingPlayerStore{store, wrappedStore{store}, logger}
+}
+
+func (l *loggingPlayerStore) GetPlayerScore(name string) int {
+	wins := l.PlayerStore.GetPlayerScore(name)
+	l.logger.Debug("read player score", "player", name, "wins", wins)
+	return wins
+}
+
+func (l *loggingPlayerStore) RecordWin(name string) {
+	l.PlayerStore.RecordWin(name)
+	l.logger.Info("recorded win", "player", name)
+}
+
+func (l *loggingPlayerStore) RecordAttendance(name string) {
+	l.PlayerStore.RecordAttendance(name)
+	l.logger.Debug("recorded attendance", "player", name)
+}
+
+func (l *loggingPlayerStore) UndoLastWin() (string, error) {
+	name, err := l.PlayerStore.UndoLastWin()
+	if err != nil {
+		l.logger.Warn("could not undo last win", "err", err)
+	} else {
+		l.logger.Info("undid win", "player", name)
+	}
+	return name, err
+}
+
+func (l *loggingPlayerStore) GetLeague() League {
+	league := l.PlayerStore.GetLeague()
+	l.logger.Debug("read league", "players", len(league))
+	return league
+}
+
+func (l *loggingPlayerStore) ImportPlayers(players []Player) error {
+	err := ImportPlayers(l.PlayerStore, players)
+	if err != nil {
+		l.logger.Warn("could not import players", "players", len(players), "err", err)
+	} else {
+		l.logger.Info("imported players", "players", len(players))
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package poker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	t.Run("writes key=value text lines", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := NewLoggerWithClock(NewFakeClock(), &out, LevelInfo, LogFormatText)
+
+		logger.With("game_id", "abc").Info("game won", "winner", "Chris Jones", "after", 90*time.Second)
+
+		assertResponseBody(t, out.String(), `time=2021-01-01T19:00:00Z level=info msg="game won" game_id=abc winner="Chris Jones" after=1m30s`+"\n")
+	})
+	t.Run("writes one JSON object per line", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := NewLoggerWithClock(NewFakeClock(), &out, LevelInfo, LogFormatJSON)
+
+		logger.With("request_id", "r1").Warn("could not undo last win", "err", errors.New("nothing to undo"), "wins", 3)
+
+		var line map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("log line %q isn't JSON, %v", out.String(), err)
+		}
+		want := map[string]interface{}{
+			"time":       "2021-01-01T19:00:00Z",
+			"level":      "warn",
+			"msg":        "could not undo last win",
+			"request_id": "r1",
+			"err":        "nothing to undo",
+			"wins":       float64(3),
+		}
+		for key, value := range want {
+			if line[key] != value {
+				t.Errorf("got %s %v, want %v", key, line[key], value)
+			}
+		}
+	})
+	t.Run("leaves out lines below its level", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := NewLogger(&out, LevelWarn, LogFormatText)
+
+		logger.Debug("read league")
+		logger.Info("recorded win")
+		logger.Error("problem compacting event log")
+
+		if got := strings.Count(out.String(), "\n"); got != 1 {
+			t.Errorf("got %d lines, want 1: %q", got, out.String())
+		}
+	})
+	t.Run("parses levels", func(t *testing.T) {
+		level, err := ParseLogLevel("DEBUG")
+		assertNoError(t, err)
+		if level != LevelDebug {
+			t.Errorf("got %v, want debug", level)
+		}
+
+		if _, err := ParseLogLevel("loud"); err == nil {
+			t.Error("expected an error for an unknown level")
+		}
+	})
+}
+
+func TestRequestLogging(t *testing.T) {
+	t.Run("logs each request under its ID", func(t *testing.T) {
+		var out bytes.Buffer
+		store := &StubPlayerStore{scores: map[string]int{"Pepper": 20}}
+		server, _ := NewPlayerServer(store, dummyGame, WithLogger(NewLogger(&out, LevelDebug, LogFormatText)))
+
+		request := newPlayersRequest(http.MethodGet, "Pepper")
+		request.Header.Set("X-Request-ID", "req-42")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if got := response.Header().Get("X-Request-ID"); got != "req-42" {
+			t.Errorf("got request ID %q, want req-42", got)
+		}
+		for _, want := range []string{
+			`msg="read player score" request_id=req-42 player=Pepper wins=20`,
+			`msg="served request" request_id=req-42 method=GET path=/players/Pepper status=200`,
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("log %q doesn't contain %q", out.String(), want)
+			}
+		}
+	})
+	t.Run("logs the store's changes under the request's ID", func(t *testing.T) {
+		var out bytes.Buffer
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithLogger(NewLogger(&out, LevelInfo, LogFormatText)))
+
+		request := newPlayersRequest(http.MethodPost, "Pepper")
+		request.Header.Set("X-Request-ID", "req-43")
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		want := `msg="recorded win" request_id=req-43 player=Pepper`
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("log %q doesn't contain %q", out.String(), want)
+		}
+	})
+	t.Run("makes up an ID when there isn't a usable one", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithLogger(DiscardLogger))
+
+		request := newLeagueRequest(http.MethodGet)
+		request.Header.Set("X-Request-ID", "has spaces in it")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		got := response.Header().Get("X-Request-ID")
+		if got == "" || got == "has spaces in it" {
+			t.Errorf("got request ID %q, want a new one", got)
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package poker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsContentType = "text/plain; version=0.0.4"
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histograms.
+var DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects what the server is doing and writes it out in the
+// Prometheus text format.
+type Metrics struct {
+	mu sync.Mutex
+
+	requests        *counterVec
+	requestDuration *histogramVec
+	wsConnections   *counterVec
+	gamesInProgress *counterVec
+	blindAlerts     *counterVec
+	storeDuration   *histogramVec
+	storeErrors     *counterVec
+	gaugeFuncs      []gaugeFunc
+}
+
+type gaugeFunc struct {
+	name, help string
+	value      func() float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:        newCounterVec("poker_http_requests_total", "counter", "HTTP requests served, by route, method and status code.", "route", "method", "code"),
+		requestDuration: newHistogramVec("poker_http_request_duration_seconds", "How long HTTP requests took, by route. WebSocket routes last as long as the connection.", "route"),
+		wsConnections:   newCounterVec("poker_websocket_connections", "gauge", "WebSocket connections currently open, by route.", "route"),
+		gamesInProgress: newCounterVec("poker_games_in_progress", "gauge", "Games started over a WebSocket that haven't finished yet."),
+		blindAlerts:     newCounterVec("poker_blind_alerts_total", "counter", "Blind alerts fired."),
+		storeDuration:   newHistogramVec("poker_store_operation_duration_seconds", "How long player store operations took, by operation.", "operation"),
+		storeErrors:     newCounterVec("poker_store_operation_errors_total", "counter", "Player store operations that returned an error, by operation.", "operation"),
+	}
+}
+
+// Instrument wraps next so that its requests are counted and timed under
+// route.
+func (m *Metrics) Instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.requests.add(1, route, r.Method, strconv.Itoa(recorder.status))
+		m.requestDuration.observe(time.Since(start).Seconds(), route)
+	})
+}
+
+func (m *Metrics) ConnectionOpened(route string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wsConnections.add(1, route)
+}
+
+func (m *Metrics) ConnectionClosed(route string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wsConnections.add(-1, route)
+}
+
+func (m *Metrics) GameStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gamesInProgress.add(1)
+}
+
+func (m *Metrics) GameEnded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gamesInProgress.add(-1)
+}
+
+// Alert counts a blind alert, so Metrics can be one of a ScheduledAlerter's
+// sinks.
+func (m *Metrics) Alert(amount int, to io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blindAlerts.add(1)
+}
+
+// ObserveStoreOperation records how long a store operation took and
+// whether it failed.
+func (m *Metrics) ObserveStoreOperation(operation string, took time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.storeDuration.observe(took.Seconds(), operation)
+	if err != nil {
+		m.storeErrors.add(1, operation)
+	}
+}
+
+// GaugeFunc adds a gauge whose value is read from value whenever the
+// metrics are written out.
+func (m *Metrics) GaugeFunc(name, help string, value func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gaugeFuncs = append(m.gaugeFuncs, gaugeFunc{name, help, value})
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := &countingWriter{w: w}
+	m.requests.write(out)
+	m.requestDuration.write(out)
+	m.wsConnections.write(out)
+	m.gamesInProgress.write(out)
+	m.blindAlerts.write(out)
+	m.storeDuration.write(out)
+	m.storeErrors.write(out)
+	for _, gauge := range m.gaugeFuncs {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", gauge.name, gauge.help, gauge.name, gauge.name, formatMetricValue(gauge.value()))
+	}
+	return out.n, out.err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", metricsContentType)
+	m.WriteTo(w)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack lets WebSocket upgrades through the recorder.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer can't be hijacked")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// counterVec is a counter or gauge with a value for each set of labels.
+type counterVec struct {
+	name, kind, help string
+	labels           []string
+	values           map[string]float64
+}
+
+func newCounterVec(name, kind, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, kind: kind, help: help, labels: labels, values: map[string]float64{}}
+}
+
+func (c *counterVec) add(delta float64, labelValues ...string) {
+	c.values[strings.Join(labelValues, "\xff")] += delta
+}
+
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", c.name, c.help, c.name, c.kind)
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, splitKey(key, len(c.labels))), formatMetricValue(c.values[key]))
+	}
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	series     map[string]*histogram
+}
+
+type histogram struct {
+	counts []int
+	sum    float64
+	count  int
+}
+
+func newHistogramVec(name, help string, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, series: map[string]*histogram{}}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{counts: make([]int, len(DefaultLatencyBuckets))}
+		h.series[key] = series
+	}
+
+	for i, bound := range DefaultLatencyBuckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+	for _, key := range keys {
+		series := h.series[key]
+		values := splitKey(key, len(h.labels))
+		for i, bound := range DefaultLatencyBuckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, append(values, formatMetricValue(bound))), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, append(values, "+Inf")), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, values), formatMetricValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, values), series.count)
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitKey turns a series key back into its label values.
+func splitKey(key string, labels int) []string {
+	if labels == 0 {
+		return nil
+	}
+	return strings.Split(key, "\xff")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelValueEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type metricsPlayerStore struct {
+	PlayerStore
+	wrappedStore
+	metrics *Metrics
+}
+
+// NewMetricsPlayerStore wraps store so that how long each operation takes,
+// and which ones fail, is recorded in metrics.
+func NewMetricsPlayerStore(store PlayerStore, metrics *Metrics) PlayerStore {
+	return &metricsPlayerStore{store, wrappedStore{store}, metrics}
+}
+
+func (m *metricsPlayerStore) GetPlayerScore(name string) int {
+	defer m.observe("get_player_score", time.Now(), nil)
+	return m.PlayerStore.GetPlayerScore(name)
+}
+
+func (m *metricsPlayerStore) RecordWin(name string) {
+	defer m.observe("record_win", time.Now(), nil)
+	m.PlayerStore.RecordWin(name)
+}
+
+func (m *metricsPlayerStore) RecordAttendance(name string) {
+	defer m.observe("record_attendance", time.Now(), nil)
+	m.PlayerStore.RecordAttendance(name)
+}
+
+func (m *metricsPlayerStore) UndoLastWin() (name string, err error) {
+	defer func(start time.Time) { m.observe("undo_last_win", start, err) }(time.Now())
+	return m.PlayerStore.UndoLastWin()
+}
+
+func (m *metricsPlayerStore) GetLeague() League {
+	defer m.observe("get_league", time.Now(), nil)
+	return m.PlayerStore.GetLeague()
+}
+
+func (m *metricsPlayerStore) ImportPlayers(players []Player) (err error) {
+	defer func(start time.Time) { m.observe("import_players", start, err) }(time.Now())
+	return ImportPlayers(m.PlayerStore, players)
+}
+
+func (m *metricsPlayerStore) observe(operation string, start time.Time, err error) {
+	m.metrics.ObserveStoreOperation(operation, time.Since(start), err)
+}
@@ -0,0 +1,98 @@
+package poker
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("counts and times requests by route", func(t *testing.T) {
+		metrics := NewMetrics()
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithMetrics(metrics))
+
+		server.ServeHTTP(httptest.NewRecorder(), newLeagueRequest(http.MethodGet))
+		server.ServeHTTP(httptest.NewRecorder(), newPlayersRequest(http.MethodGet, "Apollo"))
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assertMetricsContain(t, response.Body.String(),
+			`poker_http_requests_total{route="/league",method="GET",code="200"} 1`,
+			`poker_http_requests_total{route="/players/",method="GET",code="404"} 1`,
+			`poker_http_request_duration_seconds_bucket{route="/league",le="+Inf"} 1`,
+			`poker_http_request_duration_seconds_count{route="/players/"} 1`,
+		)
+	})
+	t.Run("tracks WebSocket connections and games in progress", func(t *testing.T) {
+		metrics := NewMetrics()
+		player, _ := NewPlayerServer(dummyPlayerStore, &GameSpy{}, WithMetrics(metrics))
+		server := httptest.NewServer(player)
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		writeWSMessage(t, ws, "Paul, Rand")
+
+		assertMetricsEventuallyContain(t, metrics,
+			`poker_websocket_connections{route="/ws"} 1`,
+			"poker_games_in_progress 1",
+		)
+
+		ws.Close()
+
+		assertMetricsEventuallyContain(t, metrics,
+			`poker_websocket_connections{route="/ws"} 0`,
+			"poker_games_in_progress 0",
+			`poker_http_requests_total{route="/ws",method="GET",code="101"} 1`,
+		)
+	})
+	t.Run("times store operations and counts their errors", func(t *testing.T) {
+		metrics := NewMetrics()
+		store := NewMetricsPlayerStore(NewInMemoryPlayerStore(), metrics)
+
+		store.RecordWin("Paul")
+		store.UndoLastWin()
+		store.UndoLastWin()
+		metrics.Alert(100, nil)
+
+		assertMetricsContain(t, writeMetrics(metrics),
+			`poker_store_operation_duration_seconds_count{operation="record_win"} 1`,
+			`poker_store_operation_duration_seconds_count{operation="undo_last_win"} 2`,
+			`poker_store_operation_errors_total{operation="undo_last_win"} 1`,
+			"poker_blind_alerts_total 1",
+		)
+	})
+}
+
+func writeMetrics(metrics *Metrics) string {
+	out := &bytes.Buffer{}
+	metrics.WriteTo(out)
+	return out.String()
+}
+
+// assertMetricsEventuallyContain waits up to a second for metrics to have
+// every one of lines, as WebSocket handlers update them in the background.
+func assertMetricsEventuallyContain(t testing.TB, metrics *Metrics, lines ...string) {
+	t.Helper()
+	eventually(func() bool {
+		got := writeMetrics(metrics)
+		for _, line := range lines {
+			if !strings.Contains(got, line) {
+				return false
+			}
+		}
+		return true
+	})
+	assertMetricsContain(t, writeMetrics(metrics), lines...)
+}
+
+func assertMetricsContain(t testing.TB, got string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("metrics are missing %s, got\n%s", line, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package poker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const htmlContentType = "text/html; charset=utf-8"
+
+var pageFuncs = template.FuncMap{
+	"playerLink": playerLink,
+}
+
+// LeagueRow is a line of the league table page.
+type LeagueRow struct {
+	Player
+	Position int
+	WinRate  string
+	Link     string
+}
+
+type LeaguePage struct {
+	Players []LeagueRow
+}
+
+// PlayerPage is a player's profile. Found is false when there is nobody by
+// that name, and HasHistory false when the store doesn't keep the events
+// their wins and games are read from. Unlisted counts the wins from before
+// the history starts, and Partial says there may be games missing too.
+type PlayerPage struct {
+	Player
+	Found      bool
+	Position   int
+	OutOf      int
+	WinRate    string
+	HasHistory bool
+	Partial    bool
+	Unlisted   int
+	WinTimes   []time.Time
+	Played     []GameRecord
+}
+
+type GamesPage struct {
+	HasHistory bool
+	Partial    bool
+	Games      []GameRecord
+}
+
+// wantsHTML is true for requests from browsers, which ask for text/html;
+// API clients get JSON as before. The response varies by Accept either way.
+func wantsHTML(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Accept")
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// render executes the page template name into a buffer first, so a failure
+// part way through becomes a 500 rather than half a page.
+func (p *PlayerServer) render(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	tmpl, err := p.pageTemplates()
+	var page bytes.Buffer
+	if err == nil {
+		err = tmpl.ExecuteTemplate(&page, name, data)
+	}
+	if err != nil {
+		LoggerFrom(r.Context()).Error("problem rendering page", "page", name, "err", err)
+		http.Error(w, "the page is unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", htmlContentType)
+	w.WriteHeader(status)
+	page.WriteTo(w)
+}
+
+func (p *PlayerServer) leaguePage(w http.ResponseWriter, r *http.Request) {
+	league := rankedLeague(p.storeFor(r).GetLeague())
+
+	page := LeaguePage{Players: make([]LeagueRow, len(league))}
+	for i, player := range league {
+		page.Players[i] = LeagueRow{
+			Player:   player,
+			Position: i + 1,
+			WinRate:  winRate(player),
+			Link:     playerLink(player.Name),
+		}
+	}
+	p.render(w, r, http.StatusOK, "league.html", page)
+}
+
+func (p *PlayerServer) playerPage(w http.ResponseWriter, r *http.Request) {
+	name := getPlayerName(r.URL.Path)
+	store := p.storeFor(r)
+	league := rankedLeague(store.GetLeague())
+
+	page := PlayerPage{Player: Player{Name: name}, OutOf: len(league)}
+	for i, player := range league {
+		if player.Name == name {
+			page.Player = player
+			page.Found = true
+			page.Position = i + 1
+			page.WinRate = winRate(player)
+		}
+	}
+	if !page.Found {
+		p.render(w, r, http.StatusNotFound, "player.html", page)
+		return
+	}
+
+	if source, ok := store.(EventSource); ok {
+		events := source.Events()
+		page.HasHistory = events != nil
+		page.Partial = historyIsPartial(events)
+		page.WinTimes = PlayerWins(events, name)
+		page.Played = PlayerGames(events, name)
+		if missing := page.Wins - len(page.WinTimes); page.HasHistory && missing > 0 {
+			page.Unlisted = missing
+		}
+	}
+	p.render(w, r, http.StatusOK, "player.html", page)
+}
+
+func (p *PlayerServer) gamesHandler(w http.ResponseWriter, r *http.Request) {
+	var page GamesPage
+	if source, ok := p.storeFor(r).(EventSource); ok {
+		events := source.Events()
+		page.HasHistory = events != nil
+		page.Partial = historyIsPartial(events)
+		page.Games = GameHistory(events)
+	}
+
+	if wantsHTML(w, r) {
+		p.render(w, r, http.StatusOK, "games.html", page)
+		return
+	}
+	if page.Games == nil {
+		page.Games = []GameRecord{}
+	}
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(page.Games)
+}
+
+// rankedLeague is league sorted for display, most wins first, then fewest
+// games, then by name.
+func rankedLeague(league League) League {
+	ranked := append(League{}, league...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.Games != b.Games {
+			return a.Games < b.Games
+		}
+		return a.Name < b.Name
+	})
+	return ranked
+}
+
+func winRate(player Player) string {
+	if player.Games == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(player.Wins)/float64(player.Games))
+}
+
+func playerLink(name string) string {
+	return "/players/" + url.PathEscape(name)
+}
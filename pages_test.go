@@ -0,0 +1,168 @@
+package poker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLeaguePage(t *testing.T) {
+	t.Run("browsers get the league as an HTML table", func(t *testing.T) {
+		store := &StubPlayerStore{league: []Player{
+			{Name: "Tiest", Wins: 14, Games: 30},
+			{Name: "Cleo", Wins: 32, Games: 40},
+			{Name: "Chris Jones", Wins: 20, Games: 20},
+		}}
+		server, _ := NewPlayerServer(store, dummyGame)
+
+		response := getPage(t, server, "/league")
+
+		assertStatus(t, response, http.StatusOK)
+		assertHTMLContains(t, response,
+			`<td>1</td>`,
+			`<a href="/players/Cleo">Cleo</a>`,
+			`<a href="/players/Chris%20Jones">Chris Jones</a>`,
+			`<td>80%</td>`,
+		)
+		body := response.Body.String()
+		if strings.Index(body, "Cleo") > strings.Index(body, "Chris Jones") || strings.Index(body, "Chris Jones") > strings.Index(body, "Tiest") {
+			t.Errorf("expected the table ordered by wins, got %s", body)
+		}
+	})
+	t.Run("says the response varies by Accept, for HTML and JSON", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{scores: map[string]int{"Pepper": 20}}, dummyGame)
+
+		for _, path := range []string{"/league", "/players/Pepper", "/games"} {
+			for _, response := range []*httptest.ResponseRecorder{getPage(t, server, path), getJSON(t, server, path)} {
+				if got := response.Header().Get("Vary"); got != "Accept" {
+					t.Errorf("got Vary %q for %s, want Accept", got, path)
+				}
+			}
+		}
+	})
+}
+
+func TestPlayerPage(t *testing.T) {
+	t.Run("shows a player's record, wins and games", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordAttendance("Cleo")
+		store.RecordAttendance("Chris")
+		store.RecordWin("Cleo")
+		server, _ := NewPlayerServer(store, dummyGame)
+
+		response := getPage(t, server, "/players/Cleo")
+
+		assertStatus(t, response, http.StatusOK)
+		assertHTMLContains(t, response,
+			"<dt>Position</dt><dd>1 of 2</dd>",
+			"<dt>Wins</dt><dd>1</dd>",
+			"<dt>Win rate</dt><dd>100%</dd>",
+			`<ul id="wins">`,
+			`<a href="/players/Chris">Chris</a>`,
+		)
+	})
+	t.Run("says how many wins are from before the history starts", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		assertNoError(t, store.ImportPlayers([]Player{{Name: "Cleo", Wins: 20, Games: 30}}))
+		store.RecordAttendance("Cleo")
+		store.RecordAttendance("Chris")
+		store.RecordWin("Cleo")
+		server, _ := NewPlayerServer(store, dummyGame)
+
+		response := getPage(t, server, "/players/Cleo")
+
+		assertHTMLContains(t, response,
+			"<dt>Wins</dt><dd>21</dd>",
+			"20 of these wins are from before the history starts",
+			"Games from before the history starts aren't listed.",
+		)
+	})
+	t.Run("says when there is nobody by that name", func(t *testing.T) {
+		server, _ := NewPlayerServer(NewInMemoryPlayerStore(), dummyGame)
+
+		response := getPage(t, server, "/players/Nobody")
+
+		assertStatus(t, response, http.StatusNotFound)
+		assertHTMLContains(t, response, "There's nobody called Nobody")
+	})
+	t.Run("API clients still get the score", func(t *testing.T) {
+		store := &StubPlayerStore{scores: map[string]int{"Pepper": 20}}
+		server, _ := NewPlayerServer(store, dummyGame)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newPlayersRequest(http.MethodGet, "Pepper"))
+
+		assertResponseBody(t, response.Body.String(), "20")
+	})
+}
+
+func TestGamesPage(t *testing.T) {
+	newStore := func() *InMemoryPlayerStore {
+		store := NewInMemoryPlayerStore()
+		store.RecordAttendance("Cleo")
+		store.RecordAttendance("Chris")
+		store.RecordWin("Chris")
+		return store
+	}
+
+	t.Run("lists games as HTML for browsers", func(t *testing.T) {
+		server, _ := NewPlayerServer(newStore(), dummyGame)
+
+		response := getPage(t, server, "/games")
+
+		assertStatus(t, response, http.StatusOK)
+		assertHTMLContains(t, response, `<td><a href="/players/Chris">Chris</a></td>`, `<a href="/players/Cleo">Cleo</a>, <a href="/players/Chris">Chris</a>`)
+	})
+	t.Run("lists games as JSON for API clients", func(t *testing.T) {
+		server, _ := NewPlayerServer(newStore(), dummyGame)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/games", nil))
+
+		var games []GameRecord
+		if err := json.NewDecoder(response.Body).Decode(&games); err != nil {
+			t.Fatalf("unable to parse games %q, %v", response.Body, err)
+		}
+		if len(games) != 1 || games[0].Winner != "Chris" || len(games[0].Players) != 2 {
+			t.Errorf("got %+v, want Chris winning a game with Cleo", games)
+		}
+	})
+	t.Run("says when the store keeps no history", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame)
+
+		response := getPage(t, server, "/games")
+
+		assertHTMLContains(t, response, "doesn't keep a history")
+	})
+}
+
+func getPage(t testing.TB, server http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func getJSON(t testing.TB, server http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+	return response
+}
+
+func assertHTMLContains(t testing.TB, response *httptest.ResponseRecorder, want ...string) {
+	t.Helper()
+	if got := response.Header().Get("content-type"); got != htmlContentType {
+		t.Errorf("got content-type %q, want %q", got, htmlContentType)
+	}
+	body := response.Body.String()
+	for _, w := range want {
+		if !strings.Contains(body, w) {
+			t.Errorf("page doesn't contain %q:\n%s", w, body)
+		}
+	}
+}
@@ -1,14 +1,15 @@
 package poker
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"io"
-	"log"
+	"io/fs"
 	"net/http"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,121 +17,593 @@ import (
 type PlayerStore interface {
 	GetPlayerScore(name string) int
 	RecordWin(name string)
+	RecordAttendance(name string)
+	UndoLastWin() (string, error)
 	GetLeague() League
 }
 
 type PlayerServer struct {
 	store PlayerStore
 	http.Handler
-	template *template.Template
-	game     Game
+	templates    *template.Template
+	assetTags    map[string]string
+	templatePath string
+	assets       fs.FS
+	reloadAssets bool
+	game         Game
+	webhooks     *Webhooks
+	broadcaster  *Broadcaster
+	adminToken   string
+	metrics      *Metrics
+	logger       *Logger
+	dataDir      string
+
+	connMu      sync.Mutex
+	connections map[string]int
+	sockets     map[*playerServerWS]struct{}
+	draining    bool
+	// playing is set while a WebSocket client has a game running, as the
+	// game and its clock are shared by every connection.
+	playing  bool
+	handlers sync.WaitGroup
+}
+
+type ServerOption func(p *PlayerServer)
+
+// WithWebhooks sends game and result events to hooks. Their subscriptions
+// are managed at /webhooks, which needs the admin token like /undo.
+func WithWebhooks(hooks *Webhooks) ServerOption {
+	return func(p *PlayerServer) {
+		p.webhooks = hooks
+	}
+}
+
+// WithBroadcaster lets spectators follow blind alerts by connecting a
+// WebSocket to /watch.
+func WithBroadcaster(broadcaster *Broadcaster) ServerOption {
+	return func(p *PlayerServer) {
+		p.broadcaster = broadcaster
+	}
+}
+
+// WithAdminToken enables the endpoints that change recorded results or
+// webhook subscriptions, for requests carrying "Authorization: Bearer token".
+func WithAdminToken(token string) ServerOption {
+	return func(p *PlayerServer) {
+		p.adminToken = token
+	}
+}
+
+// WithMetrics counts and times every request, along with WebSocket
+// connections and games in progress, and serves them at /metrics.
+func WithMetrics(metrics *Metrics) ServerOption {
+	return func(p *PlayerServer) {
+		p.metrics = metrics
+	}
+}
+
+// WithLogger logs each request, and each game played over a WebSocket,
+// under an ID of its own. Without it DefaultLogger is used.
+func WithLogger(logger *Logger) ServerOption {
+	return func(p *PlayerServer) {
+		p.logger = logger
+	}
+}
+
+// WithTemplate serves the game page from the template at path instead of
+// the game.html built in.
+func WithTemplate(path string) ServerOption {
+	return func(p *PlayerServer) {
+		p.templatePath = path
+	}
 }
 
 type Player struct {
-	Name string
-	Wins int
+	Name  string
+	Wins  int
+	Games int
 }
 
 type playerServerWS struct {
 	*websocket.Conn
+	writeMu sync.Mutex
+	logger  *Logger
 }
 
 func newPlayerServerWS(w http.ResponseWriter, r *http.Request) *playerServerWS {
+	logger := LoggerFrom(r.Context())
 	conn, err := wsUpgrader.Upgrade(w, r, nil)
 
 	if err != nil {
-		log.Printf("problem upgrading request to WebSockets, %v\n", err)
+		logger.Warn("problem upgrading request to WebSockets", "err", err)
 	}
 
-	return &playerServerWS{conn}
+	return &playerServerWS{Conn: conn, logger: logger}
 }
 
-func (w *playerServerWS) WaitForMsg() string {
+func (w *playerServerWS) WaitForMsg() (string, error) {
 	_, msg, err := w.ReadMessage()
-	if err != nil {
-		log.Printf("error reading from websocket %v\n", err)
+	switch {
+	case websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway):
+		w.logger.Warn("error reading from websocket", "err", err)
+	case err != nil:
+		w.logger.Debug("websocket closed", "err", err)
 	}
-	return string(msg)
+	return string(msg), err
+}
+
+func (w *playerServerWS) Write(p []byte) (int, error) {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	if err := w.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
 const jsonContentType = "application/json"
 const htmlTemplatePath = "game.html"
 
+// GameStartedMessage and GameFinishedMessage tell a WebSocket client its
+// players or winner were accepted, in the same shape as a BlindAlertMessage.
+// Anything it sends that is turned down gets the reason back as plain text.
+var (
+	GameStartedMessage  = []byte(`{"Type":"started"}`)
+	GameFinishedMessage = []byte(`{"Type":"finished"}`)
+)
+
 var wsUpgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
-func NewPlayerServer(store PlayerStore, game Game) (*PlayerServer, error) {
+func NewPlayerServer(store PlayerStore, game Game, options ...ServerOption) (*PlayerServer, error) {
 	p := new(PlayerServer)
 
-	tmpl, err := template.ParseFiles(htmlTemplatePath)
+	p.store = store
+	p.game = game
+	p.assets = embeddedAssets
+	p.logger = DefaultLogger
+	p.connections = map[string]int{"/ws": 0}
+	p.sockets = map[*playerServerWS]struct{}{}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	if !p.reloadAssets {
+		tags, err := hashAssets(p.assets)
+		if err != nil {
+			return nil, err
+		}
+		p.assetTags = tags
+	}
+
+	tmpl, err := p.loadTemplates()
 
 	if err != nil {
-		return nil, fmt.Errorf("problem opening %s %v", htmlTemplatePath, err)
+		return nil, err
 	}
 
-	p.template = tmpl
-	p.store = store
-	p.game = game
+	p.templates = tmpl
 
 	router := http.NewServeMux()
-	router.Handle("/game", http.HandlerFunc(p.playGame))
-	router.Handle("/ws", http.HandlerFunc(p.websocket))
-	router.Handle("/league", http.HandlerFunc(p.leagueHandler))
-	router.Handle("/players/", http.HandlerFunc(p.playersHandler))
+	handle := func(pattern string, handler http.Handler) {
+		if p.metrics != nil {
+			handler = p.metrics.Instrument(pattern, handler)
+		}
+		router.Handle(pattern, handler)
+	}
 
-	p.Handler = router
+	handle("/game", http.HandlerFunc(p.playGame))
+	handle("/ws", p.refuseWhileShuttingDown(p.websocket))
+	handle("/static/", http.HandlerFunc(p.staticHandler))
+	handle("/league", http.HandlerFunc(p.leagueHandler))
+	handle("/games", http.HandlerFunc(p.gamesHandler))
+	handle("/league.csv", http.HandlerFunc(p.leagueCSVHandler))
+	handle("/players/", http.HandlerFunc(p.playersHandler))
+	handle("/healthz", http.HandlerFunc(p.healthHandler))
+	handle("/readyz", http.HandlerFunc(p.readyHandler))
+
+	if p.broadcaster != nil {
+		p.connections["/watch"] = 0
+		handle("/watch", p.refuseWhileShuttingDown(p.watch))
+	}
+
+	if p.adminToken != "" {
+		handle("/undo", p.requireAdmin(http.HandlerFunc(p.undoHandler)))
+		handle("/import", p.requireAdmin(http.HandlerFunc(p.importHandler)))
+	}
+
+	if p.webhooks != nil && p.adminToken != "" {
+		handle("/webhooks", p.requireAdmin(http.HandlerFunc(p.webhooksHandler)))
+		handle("/webhooks/", p.requireAdmin(http.HandlerFunc(p.webhookHandler)))
+	}
+
+	if p.metrics != nil {
+		router.Handle("/metrics", p.metrics)
+	}
+
+	p.Handler = p.logRequests(router)
 	return p, nil
 }
 
 func (p *PlayerServer) playGame(w http.ResponseWriter, r *http.Request) {
-	p.template.Execute(w, nil)
+	p.render(w, r, http.StatusOK, htmlTemplatePath, nil)
 }
 
 func (p *PlayerServer) websocket(w http.ResponseWriter, r *http.Request) {
 	ws := newPlayerServerWS(w, r)
+	if ws.Conn == nil {
+		return
+	}
+	closeSocket, ok := p.openSocket(ws)
+	if !ok {
+		return
+	}
+	defer closeSocket()
+	defer p.trackConnection("/ws")()
+
+	logger := ws.logger.With("game_id", NewLogID())
+	ws.logger = logger
+
+	var players []string
+	for {
+		playersMsg, err := ws.WaitForMsg()
+		if err != nil {
+			return
+		}
+
+		players, err = parsePlayers(playersMsg)
+		if err == nil && !p.takeTable() {
+			err = ErrGameInProgress
+		}
+		if err == nil {
+			break
+		}
+		logger.Debug("rejected players", "input", playersMsg, "err", err)
+		ws.Write([]byte(err.Error()))
+	}
+	defer p.leaveTable()
+
+	if loggingGame, ok := p.game.(LoggingGame); ok {
+		loggingGame.SetLogger(logger)
+	}
+	logger.Info("game started", "players", strings.Join(players, ","))
+	ws.Write(GameStartedMessage)
+	p.game.Start(players, ws)
+	if p.metrics != nil {
+		p.metrics.GameStarted()
+		defer p.metrics.GameEnded()
+	}
+	finished := false
+	defer func() {
+		// A client that goes away mid-game has abandoned it, but one sent
+		// away by a shutdown leaves it running to be saved.
+		if abandonable, ok := p.game.(AbandonableGame); ok && !finished && !p.shuttingDown() {
+			abandonable.Abandon()
+		}
+	}()
+
+	for {
+		msg, err := ws.WaitForMsg()
+		if err != nil {
+			return
+		}
+
+		if clock, ok := p.game.(BlindClock); ok {
+			handled, err := RunClockCommand(clock, msg)
+			if err != nil {
+				logger.Debug("clock command failed", "command", msg, "err", err)
+				ws.Write([]byte(err.Error()))
+			}
+			if handled {
+				continue
+			}
+		}
+
+		if err := p.game.Finish(msg); err != nil {
+			logger.Debug("could not finish game", "input", msg, "err", err)
+			ws.Write([]byte(err.Error()))
+			continue
+		}
+		logger.Info("game finished", "input", msg)
+		finished = true
+		ws.Write(GameFinishedMessage)
+		return
+	}
+}
+
+func (p *PlayerServer) watch(w http.ResponseWriter, r *http.Request) {
+	ws := newPlayerServerWS(w, r)
+	if ws.Conn == nil {
+		return
+	}
+	closeSocket, ok := p.openSocket(ws)
+	if !ok {
+		return
+	}
+	defer closeSocket()
+	defer ws.Close()
+	defer p.trackConnection("/watch")()
+
+	p.broadcaster.Add(ws)
+	defer p.broadcaster.Remove(ws)
+
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
 
-	numberOfPlayersMsg := ws.WaitForMsg()
-	numberOfPlayers, _ := strconv.Atoi(string(numberOfPlayersMsg))
+// takeTable reports whether a new game can start, and if it can keeps any
+// other from starting until leaveTable is called.
+func (p *PlayerServer) takeTable() bool {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	if p.playing {
+		return false
+	}
+	p.playing = true
+	return true
+}
 
-	p.game.Start(numberOfPlayers, io.Discard)
+func (p *PlayerServer) leaveTable() {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.playing = false
+}
 
-	winner := ws.WaitForMsg()
+// trackConnection counts a WebSocket as open on route until the returned
+// function is called.
+func (p *PlayerServer) trackConnection(route string) func() {
+	p.countConnection(route, 1)
+	if p.metrics != nil {
+		p.metrics.ConnectionOpened(route)
+	}
+	return func() {
+		p.countConnection(route, -1)
+		if p.metrics != nil {
+			p.metrics.ConnectionClosed(route)
+		}
+	}
+}
 
-	p.game.Finish(string(winner))
+func (p *PlayerServer) countConnection(route string, delta int) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.connections[route] += delta
 }
 
 func (p *PlayerServer) leagueHandler(w http.ResponseWriter, r *http.Request) {
+	if wantsHTML(w, r) {
+		p.leaguePage(w, r)
+		return
+	}
 	w.Header().Set("content-type", jsonContentType)
-	json.NewEncoder(w).Encode(p.store.GetLeague())
+	json.NewEncoder(w).Encode(p.storeFor(r).GetLeague())
 	w.WriteHeader(http.StatusOK)
 }
 
+func (p *PlayerServer) leagueCSVHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", csvContentType)
+	w.Header().Set("content-disposition", `attachment; filename="league.csv"`)
+	if err := ExportLeague(w, p.storeFor(r), FormatCSV); err != nil {
+		LoggerFrom(r.Context()).Warn("problem exporting league", "err", err)
+	}
+}
+
+// importHandler adds the results in the request body to the league. The
+// format comes from the content type, and ?dry_run=true only validates them.
+func (p *PlayerServer) importHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := FormatJSON
+	if strings.HasPrefix(r.Header.Get("content-type"), csvContentType) {
+		format = FormatCSV
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	report, err := ImportLeague(p.storeFor(r), r.Body, format, dryRun)
+	if err != nil && err != ErrInvalidImport {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	if err == ErrInvalidImport {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
 func (p *PlayerServer) playersHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		p.processWin(w, r)
 	case http.MethodGet:
+		if wantsHTML(w, r) {
+			p.playerPage(w, r)
+			return
+		}
 		p.showScore(w, r)
 	}
 }
 
 func (p *PlayerServer) showScore(w http.ResponseWriter, r *http.Request) {
 	player := getPlayerName(r.URL.Path)
-	score := p.store.GetPlayerScore(player)
+	score := p.storeFor(r).GetPlayerScore(player)
 	if score == 0 {
 		w.WriteHeader(http.StatusNotFound)
 	}
-	fmt.Fprint(w, p.store.GetPlayerScore(player))
+	fmt.Fprint(w, score)
 }
 
 func (p *PlayerServer) processWin(w http.ResponseWriter, r *http.Request) {
 	player := getPlayerName(r.URL.Path)
-	p.store.RecordWin(player)
+	p.storeFor(r).RecordWin(player)
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (p *PlayerServer) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", jsonContentType)
+		json.NewEncoder(w).Encode(p.webhooks.Subscriptions())
+	case http.MethodPost:
+		p.subscribeWebhook(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *PlayerServer) subscribeWebhook(w http.ResponseWriter, r *http.Request) {
+	var sub WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, fmt.Sprintf("problem parsing webhook subscription, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := p.webhooks.Subscribe(sub)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+func (p *PlayerServer) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/webhooks/")
+
+	switch {
+	case r.Method == http.MethodDelete:
+		if !p.webhooks.Unsubscribe(id) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasSuffix(id, "/deliveries"):
+		deliveries, ok := p.webhooks.Deliveries(strings.TrimSuffix(id, "/deliveries"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("content-type", jsonContentType)
+		json.NewEncoder(w).Encode(deliveries)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// probePaths are polled by the process supervisor, so serving them is only
+// logged at debug level.
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// logRequests gives every request an ID, taken from its X-Request-ID header
+// if it has a sensible one, and a logger carrying it, then logs the request
+// once it has been served.
+func (p *PlayerServer) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = NewLogID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := p.logger.With("request_id", id)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next.ServeHTTP(recorder, r.WithContext(ContextWithLogger(r.Context(), logger)))
+
+		logged := logger.Info
+		if probePaths[r.URL.Path] {
+			logged = logger.Debug
+		}
+		logged("served request", "method", r.Method, "path", r.URL.Path, "status", recorder.status, "duration", time.Since(start))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// storeFor is the store as seen by one request, logging under its ID.
+func (p *PlayerServer) storeFor(r *http.Request) PlayerStore {
+	return NewLoggingPlayerStore(p.store, LoggerFrom(r.Context()))
+}
+
+func (p *PlayerServer) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(p.adminToken)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken is the token from an "Authorization: Bearer token" header,
+// reporting false if the request has no header in that scheme, whose name
+// can be in any case.
+func bearerToken(r *http.Request) (string, bool) {
+	const scheme = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return "", false
+	}
+	return header[len(scheme):], true
+}
+
+// undoHandler takes back the last recorded win, giving it to the winner
+// query parameter instead when there is one.
+func (p *PlayerServer) undoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var result struct {
+		Undone   string
+		Recorded string `json:",omitempty"`
+	}
+	var err error
+
+	if winner := r.URL.Query().Get("winner"); winner != "" {
+		result.Undone, err = CorrectLastWin(p.storeFor(r), winner)
+		result.Recorded = winner
+	} else {
+		result.Undone, err = p.storeFor(r).UndoLastWin()
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(result)
+}
+
 func getPlayerName(path string) string {
 	return strings.TrimPrefix(path, "/players/")
 }
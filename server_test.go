@@ -1,6 +1,7 @@
 package poker
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,6 +28,7 @@ func TestGETPlayers(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 	}
 	server, _ := NewPlayerServer(&store, dummyGame)
 	t.Run("Returns Pepper's score", func(t *testing.T) {
@@ -64,6 +66,7 @@ func TestStoreWins(t *testing.T) {
 		map[string]int{},
 		nil,
 		nil,
+		nil,
 	}
 	server, _ := NewPlayerServer(&store, dummyGame)
 	t.Run("Records wins on post", func(t *testing.T) {
@@ -89,12 +92,12 @@ func TestLeague(t *testing.T) {
 
 	t.Run("it returns the league table as JSON", func(t *testing.T) {
 		wantedLeague := []Player{
-			{"Cleo", 32},
-			{"Chris", 20},
-			{"Tiest", 14},
+			{Name: "Cleo", Wins: 32},
+			{Name: "Chris", Wins: 20},
+			{Name: "Tiest", Wins: 14},
 		}
 
-		store := StubPlayerStore{nil, nil, wantedLeague}
+		store := StubPlayerStore{nil, nil, wantedLeague, nil}
 		server, _ := NewPlayerServer(&store, dummyGame)
 
 		request := newLeagueRequest(http.MethodGet)
@@ -109,6 +112,97 @@ func TestLeague(t *testing.T) {
 	})
 }
 
+func TestLeagueCSV(t *testing.T) {
+	store := &StubPlayerStore{league: []Player{{Name: "Cleo", Wins: 32, Games: 40}}}
+	server, _ := NewPlayerServer(store, dummyGame)
+
+	request, _ := http.NewRequest(http.MethodGet, "/league.csv", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response, http.StatusOK)
+	assertResponseBody(t, response.Body.String(), "name,wins,games\nCleo,32,40\n")
+	if got := response.Header().Get("content-type"); got != csvContentType {
+		t.Errorf("got content type %q, want %q", got, csvContentType)
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Run("imports CSV", func(t *testing.T) {
+		store := &StubPlayerStore{}
+		server, _ := NewPlayerServer(store, dummyGame, WithAdminToken("s3cret"))
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newImportRequest("", "name,wins\nPaul,1\n"))
+
+		assertStatus(t, response, http.StatusOK)
+		AssertPlayerWin(t, store, "Paul")
+	})
+	t.Run("reports bad rows without importing on a dry run", func(t *testing.T) {
+		store := &StubPlayerStore{}
+		server, _ := NewPlayerServer(store, dummyGame, WithAdminToken("s3cret"))
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newImportRequest("?dry_run=true", "name,wins\nPaul,-1\n"))
+
+		assertStatus(t, response, http.StatusUnprocessableEntity)
+		if !strings.Contains(response.Body.String(), `"Problems":[{"Row":1,`) {
+			t.Errorf("got report %s", response.Body.String())
+		}
+	})
+}
+
+func TestUndo(t *testing.T) {
+	t.Run("needs the admin token", func(t *testing.T) {
+		store := &StubPlayerStore{winCalls: []string{"Paul"}}
+		server, _ := NewPlayerServer(store, dummyGame, WithAdminToken("s3cret"))
+
+		request, _ := http.NewRequest(http.MethodPost, "/undo", nil)
+		request.Header.Set("Authorization", "Bearer guess")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response, http.StatusUnauthorized)
+		AssertPlayerWin(t, store, "Paul")
+	})
+	t.Run("needs the token in the Bearer scheme", func(t *testing.T) {
+		store := &StubPlayerStore{winCalls: []string{"Paul"}}
+		server, _ := NewPlayerServer(store, dummyGame, WithAdminToken("s3cret"))
+
+		for _, header := range []string{"s3cret", "Basic s3cret", "Bearer", "Bearers3cret"} {
+			request, _ := http.NewRequest(http.MethodPost, "/undo", nil)
+			request.Header.Set("Authorization", header)
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			assertStatus(t, response, http.StatusUnauthorized)
+		}
+		AssertPlayerWin(t, store, "Paul")
+	})
+	t.Run("corrects the last win", func(t *testing.T) {
+		store := &StubPlayerStore{winCalls: []string{"Paul"}}
+		server, _ := NewPlayerServer(store, dummyGame, WithAdminToken("s3cret"))
+
+		request, _ := http.NewRequest(http.MethodPost, "/undo?winner=Rand", nil)
+		request.Header.Set("Authorization", "Bearer s3cret")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response, http.StatusOK)
+		assertResponseBody(t, response.Body.String(), `{"Undone":"Paul","Recorded":"Rand"}`+"\n")
+		AssertPlayerWin(t, store, "Rand")
+	})
+	t.Run("is not served without an admin token", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame)
+
+		request, _ := http.NewRequest(http.MethodPost, "/undo", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response, http.StatusNotFound)
+	})
+}
+
 // Integration Tests:
 func TestRecordingWinsAndRetrievingLeague(t *testing.T) {
 
@@ -127,7 +221,7 @@ func TestRecordingWinsAndRetrievingLeague(t *testing.T) {
 	response := httptest.NewRecorder()
 	server.ServeHTTP(response, newLeagueRequest(http.MethodGet))
 	wantedLeague := []Player{
-		{"Pepper", 3},
+		{Name: "Pepper", Wins: 3},
 	}
 
 	got := getLeagueFromResponse(t, response.Body)
@@ -155,12 +249,146 @@ func TestGame(t *testing.T) {
 		defer server.Close()
 		defer ws.Close()
 
-		writeWSMessage(t, ws, "3")
+		writeWSMessage(t, ws, "Paul, Rand, Chris")
 		writeWSMessage(t, ws, winner)
 
-		time.Sleep(10 * time.Millisecond)
-		assertStartedWith(t, *game, 3)
-		assertFinishedWith(t, *game, winner)
+		assertStartedWith(t, game, "Paul", "Rand", "Chris")
+		assertFinishedWith(t, game, winner)
+	})
+	t.Run("clock commands over the websocket do not end the game", func(t *testing.T) {
+		game := &GameSpy{}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+
+		defer server.Close()
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "Paul, Rand, Chris")
+		writeWSMessage(t, ws, "pause")
+		writeWSMessage(t, ws, "resume")
+		writeWSMessage(t, ws, "Paul")
+
+		assertFinishedWith(t, game, "Paul")
+		if _, _, commands := game.Calls(); !reflect.DeepEqual(commands, []string{"pause", "resume"}) {
+			t.Errorf("got clock commands %v", commands)
+		}
+	})
+	t.Run("a client going away mid-game abandons it", func(t *testing.T) {
+		game := &GameSpy{}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer server.Close()
+
+		writeWSMessage(t, ws, "Paul, Rand, Chris")
+		assertStartedWith(t, game, "Paul", "Rand", "Chris")
+		ws.Close()
+
+		eventually(game.WasAbandoned)
+		if !game.WasAbandoned() {
+			t.Error("the game is still running after its client went away")
+		}
+	})
+	t.Run("a second game is refused while one is being played", func(t *testing.T) {
+		clock := NewFakeClock()
+		store := NewInMemoryPlayerStore()
+		game := NewTexasHoldemWithClock(NewScheduledAlerterWithClock(clock, JSONSink), store, clock)
+		playerServer := mustMakePlayerServer(t, store, game)
+		server := httptest.NewServer(playerServer)
+		defer server.Close()
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+		first := mustDialWS(t, url)
+		defer first.Close()
+		second := mustDialWS(t, url)
+
+		writeWSMessage(t, first, "Alice, Bob")
+		eventually(func() bool { _, running := game.State(); return running })
+		clock.Advance(0)
+		assertBlindAlert(t, first, 100)
+
+		writeWSMessage(t, second, "Carol, Dave")
+		assertWSMessage(t, second, ErrGameInProgress.Error())
+		second.Close()
+		eventually(func() bool { return playerServer.openConnections()["/ws"] == 1 })
+
+		clock.Advance(7 * time.Minute)
+		assertBlindAlert(t, first, 200)
+
+		writeWSMessage(t, first, "Alice wins")
+		eventually(func() bool { return store.GetPlayerScore("Alice") == 1 })
+		assertPlayerScore(t, store.GetPlayerScore("Alice"), 1)
+
+		third := mustDialWS(t, url)
+		defer third.Close()
+		writeWSMessage(t, third, "Carol, Dave")
+		eventually(func() bool { state, _ := game.State(); return len(state.Players) > 0 && state.Players[0] == "Carol" })
+		if state, _ := game.State(); !reflect.DeepEqual(state.Players, []string{"Carol", "Dave"}) {
+			t.Errorf("got %v playing once the first game was over, want Carol and Dave", state.Players)
+		}
+	})
+	t.Run("tells the client whether its players and winner were accepted", func(t *testing.T) {
+		clock := NewFakeClock()
+		store := NewInMemoryPlayerStore()
+		game := NewTexasHoldemWithClock(NewScheduledAlerterWithClock(clock, JSONSink), store, clock)
+		server := httptest.NewServer(mustMakePlayerServer(t, store, game))
+		defer server.Close()
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "Alice")
+		assertWSMessage(t, ws, BadPlayerInputErrMsg)
+		writeWSMessage(t, ws, "Alice, Bob")
+		assertWSMessage(t, ws, string(GameStartedMessage))
+
+		writeWSMessage(t, ws, "Carol wins")
+		assertWSMessage(t, ws, ErrWinnerNotSeated.Error())
+		writeWSMessage(t, ws, "Alice wins")
+		assertWSMessage(t, ws, string(GameFinishedMessage))
+		assertPlayerScore(t, store.GetPlayerScore("Alice"), 1)
+	})
+	t.Run("a refused client going away leaves the game being played alone", func(t *testing.T) {
+		game := &GameSpy{}
+		playerServer := mustMakePlayerServer(t, dummyPlayerStore, game)
+		server := httptest.NewServer(playerServer)
+		defer server.Close()
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+		first := mustDialWS(t, url)
+		defer first.Close()
+		writeWSMessage(t, first, "Paul, Rand")
+		assertWSMessage(t, first, string(GameStartedMessage))
+
+		second := mustDialWS(t, url)
+		writeWSMessage(t, second, "Chris, Cleo")
+		assertWSMessage(t, second, ErrGameInProgress.Error())
+		second.Close()
+		eventually(func() bool { return playerServer.openConnections()["/ws"] == 1 })
+
+		if game.WasAbandoned() {
+			t.Error("abandoned the game being played when a refused client went away")
+		}
+		writeWSMessage(t, first, "Paul")
+		assertFinishedWith(t, game, "Paul")
+		assertStartedWith(t, game, "Paul", "Rand")
+	})
+	t.Run("clock commands reach a game wrapped for webhooks", func(t *testing.T) {
+		game := &GameSpy{}
+		hooked := NewWebhookGame(game, NewWebhooks(1, time.Millisecond))
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, hooked))
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+
+		defer server.Close()
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "Paul, Rand, Chris")
+		writeWSMessage(t, ws, "pause")
+		writeWSMessage(t, ws, "next")
+		writeWSMessage(t, ws, "Rand")
+
+		assertFinishedWith(t, game, "Rand")
+		if _, _, commands := game.Calls(); !reflect.DeepEqual(commands, []string{"pause", "next"}) {
+			t.Errorf("got clock commands %v, want them passed through the webhook game", commands)
+		}
 	})
 }
 
@@ -169,6 +397,13 @@ func newPlayersRequest(method, name string) *http.Request {
 	return req
 }
 
+func newImportRequest(query, body string) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, "/import"+query, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("content-type", csvContentType)
+	return req
+}
+
 func newGameRequest(method string) *http.Request {
 	req, _ := http.NewRequest(method, "/league", nil)
 	return req
@@ -202,6 +437,37 @@ func writeWSMessage(t testing.TB, conn *websocket.Conn, message string) {
 	}
 }
 
+// assertWSMessage waits up to a second for the next message on conn.
+func assertWSMessage(t testing.TB, conn *websocket.Conn, want string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("got no message over ws connection, %v", err)
+	}
+	if string(msg) != want {
+		t.Errorf("got message %q, want %q", msg, want)
+	}
+}
+
+// assertBlindAlert reads messages from conn until the blind goes up to
+// amount, giving up after a second.
+func assertBlindAlert(t testing.TB, conn *websocket.Conn, amount int) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("never got a blind alert for %d, %v", amount, err)
+		}
+		var alert BlindAlertMessage
+		json.Unmarshal(msg, &alert)
+		if alert.Type == "blind" && alert.Amount == amount {
+			return
+		}
+	}
+}
+
 func mustMakePlayerServer(t *testing.T, store PlayerStore, game Game) *PlayerServer {
 	server, err := NewPlayerServer(store, game)
 	if err != nil {
@@ -216,15 +482,37 @@ func assertResponseBody(t testing.TB, got, want string) {
 	}
 }
 
-func assertStartedWith(t testing.TB, game GameSpy, want int) {
-	if game.StartedWith != want {
-		t.Errorf("Wanted start with of %d but got %d", want, game.StartedWith)
+// assertStartedWith waits up to a second for game to be started with want,
+// as a websocket handler starts it in the background.
+func assertStartedWith(t testing.TB, game *GameSpy, want ...string) {
+	t.Helper()
+	var got []string
+	eventually(func() bool {
+		got, _, _ = game.Calls()
+		return reflect.DeepEqual(got, want)
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wanted start with %v but got %v", want, got)
+	}
+}
+
+func assertFinishedWith(t testing.TB, game *GameSpy, want string) {
+	t.Helper()
+	var got string
+	eventually(func() bool {
+		_, got, _ = game.Calls()
+		return got == want
+	})
+	if got != want {
+		t.Errorf("Wanted winner of %v but got %v", want, got)
 	}
 }
 
-func assertFinishedWith(t testing.TB, game GameSpy, want string) {
-	if game.FinishedWith != want {
-		t.Errorf("Wanted winner of %v but got %v", want, game.FinishedWith)
+// eventually polls condition until it holds or a second has gone by.
+func eventually(condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
 	}
 }
 
@@ -0,0 +1,180 @@
+package poker
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const ShellPrompt = "poker> "
+
+const ShellHelp = `Commands:
+  new-game [PLAYERS]  start a game with the players' names separated by
+                      commas, asking for them if not given; while it runs,
+                      pause, resume, next, back and add control the blind
+                      clock until "NAME wins" ends it, or abandon (or quit)
+                      gives it up and comes back here, and help lists these
+  players             list everyone who has played and how many games
+  league              show the league table
+  stats NAME          show how many games NAME has won
+  undo                take back the last recorded win
+  correct NAME        give the last recorded win to NAME instead
+  help                show this help
+  quit                leave
+`
+
+// Shell is an interactive prompt for running several games and looking
+// at the league in between them.
+type Shell struct {
+	cli   *CLI
+	store PlayerStore
+}
+
+type shellCommand struct {
+	usage   string
+	minArgs int
+	maxArgs int
+	run     func(s *Shell, args []string) bool
+}
+
+const newGameUsage = "new-game [PLAYERS]"
+
+// unlimited is the maxArgs of commands that take names, which may contain
+// spaces.
+const unlimited = -1
+
+var shellCommands = map[string]shellCommand{
+	"new-game": {newGameUsage, 0, unlimited, (*Shell).newGame},
+	"players":  {"players", 0, 0, (*Shell).players},
+	"league":   {"league", 0, 0, (*Shell).league},
+	"stats":    {"stats NAME", 1, unlimited, (*Shell).stats},
+	"undo":     {"undo", 0, 0, (*Shell).undo},
+	"correct":  {"correct NAME", 1, unlimited, (*Shell).correct},
+	"help":     {"help", 0, 0, (*Shell).help},
+	"quit":     {"quit", 0, 0, (*Shell).quit},
+}
+
+func NewShell(in io.Reader, out io.Writer, game Game, store PlayerStore) *Shell {
+	return &Shell{
+		cli:   NewCLI(in, out, game),
+		store: store,
+	}
+}
+
+// Run reads and runs commands until it is told to quit or runs out of input.
+func (s *Shell) Run() {
+	for {
+		fmt.Fprint(s.cli.out, ShellPrompt)
+		if !s.cli.in.Scan() {
+			return
+		}
+
+		fields := strings.Fields(s.cli.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if !s.runCommand(fields[0], fields[1:]) {
+			return
+		}
+	}
+}
+
+func (s *Shell) runCommand(name string, args []string) bool {
+	command, ok := shellCommands[name]
+	if !ok {
+		fmt.Fprintf(s.cli.out, "Unknown command %q, type help to see the commands.\n", name)
+		return true
+	}
+
+	if len(args) < command.minArgs || command.maxArgs != unlimited && len(args) > command.maxArgs {
+		fmt.Fprintf(s.cli.out, "Usage: %s\n", command.usage)
+		return true
+	}
+
+	return command.run(s, args)
+}
+
+func (s *Shell) newGame(args []string) bool {
+	if len(args) == 0 {
+		s.cli.PlayPoker()
+		return true
+	}
+
+	players, err := parsePlayers(strings.Join(args, " "))
+	if err != nil {
+		fmt.Fprintf(s.cli.out, "%v\nUsage: %s\n", err, newGameUsage)
+		return true
+	}
+
+	s.cli.playGame(players)
+	return true
+}
+
+func (s *Shell) players(args []string) bool {
+	players := append(League{}, s.store.GetLeague()...)
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Name < players[j].Name
+	})
+
+	if len(players) == 0 {
+		fmt.Fprintln(s.cli.out, "Nobody has played yet.")
+	}
+	for _, player := range players {
+		fmt.Fprintf(s.cli.out, "%s (%d games)\n", player.Name, player.Games)
+	}
+	return true
+}
+
+func (s *Shell) league(args []string) bool {
+	league := s.store.GetLeague()
+	if len(league) == 0 {
+		fmt.Fprintln(s.cli.out, "Nobody has played yet.")
+	}
+	for i, player := range league {
+		fmt.Fprintf(s.cli.out, "%d. %s %d\n", i+1, player.Name, player.Wins)
+	}
+	return true
+}
+
+func (s *Shell) stats(args []string) bool {
+	name := strings.Join(args, " ")
+	if s.store.GetLeague().Find(name) == nil {
+		fmt.Fprintf(s.cli.out, "No player called %s.\n", name)
+		return true
+	}
+
+	fmt.Fprintf(s.cli.out, "%s has %d wins.\n", name, s.store.GetPlayerScore(name))
+	return true
+}
+
+func (s *Shell) undo(args []string) bool {
+	name, err := s.store.UndoLastWin()
+	if err != nil {
+		fmt.Fprintln(s.cli.out, err)
+		return true
+	}
+	fmt.Fprintf(s.cli.out, "Took back a win from %s.\n", name)
+	return true
+}
+
+func (s *Shell) correct(args []string) bool {
+	winner := strings.Join(args, " ")
+	name, err := CorrectLastWin(s.store, winner)
+	if err != nil {
+		fmt.Fprintln(s.cli.out, err)
+		return true
+	}
+	fmt.Fprintf(s.cli.out, "Gave the last win to %s instead of %s.\n", winner, name)
+	return true
+}
+
+func (s *Shell) help(args []string) bool {
+	fmt.Fprint(s.cli.out, ShellHelp)
+	return true
+}
+
+func (s *Shell) quit(args []string) bool {
+	return false
+}
@@ -0,0 +1,119 @@
+package poker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestShell(t *testing.T) {
+	league := []Player{
+		{Name: "Chris", Wins: 33},
+		{Name: "Cleo", Wins: 10},
+	}
+
+	t.Run("runs several games until told to quit", func(t *testing.T) {
+		in := strings.NewReader("new-game Paul, Rand\nPaul wins\nnew-game\nRand, Chris, Cleo\nRand wins\nquit\nnew-game Paul, Rand\n")
+		store := &StubPlayerStore{}
+		game := &GameSpy{}
+
+		NewShell(in, &bytes.Buffer{}, game, store).Run()
+
+		if len(store.winCalls) != 0 {
+			t.Errorf("the shell should leave recording wins to the game, got %v", store.winCalls)
+		}
+		assertStartedWith(t, game, "Rand", "Chris", "Cleo")
+		assertFinishedWith(t, game, "Rand")
+	})
+	t.Run("shows the league and player stats", func(t *testing.T) {
+		in := strings.NewReader("league\nstats Cleo\nstats Apollo\n")
+		out := &bytes.Buffer{}
+		store := &StubPlayerStore{map[string]int{"Cleo": 10}, nil, league, nil}
+
+		NewShell(in, out, dummyGame, store).Run()
+
+		assertShellOutput(t, out,
+			"1. Chris 33\n2. Cleo 10\n",
+			"Cleo has 10 wins.\n",
+			"No player called Apollo.\n",
+		)
+	})
+	t.Run("lists players alphabetically", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		store := &StubPlayerStore{nil, nil, league, nil}
+
+		NewShell(strings.NewReader("players\n"), out, dummyGame, store).Run()
+
+		assertShellOutput(t, out, "Chris (0 games)\nCleo (0 games)\n")
+	})
+	t.Run("re-prompts after bad commands and arguments", func(t *testing.T) {
+		in := strings.NewReader("deal\nstats\nnew-game lots\nleague extra\n")
+		out := &bytes.Buffer{}
+		game := &GameSpy{}
+
+		NewShell(in, out, game, &StubPlayerStore{}).Run()
+
+		assertShellOutput(t, out,
+			"Unknown command \"deal\", type help to see the commands.\n",
+			"Usage: stats NAME\n",
+			BadPlayerInputErrMsg+"\nUsage: new-game [PLAYERS]\n",
+			"Usage: league\n",
+		)
+		if game.StartCalled {
+			t.Error("game should not have started")
+		}
+	})
+	t.Run("undoes and corrects recorded wins", func(t *testing.T) {
+		in := strings.NewReader("correct Rand\nundo\nundo\n")
+		out := &bytes.Buffer{}
+		store := &StubPlayerStore{winCalls: []string{"Paul"}}
+
+		NewShell(in, out, dummyGame, store).Run()
+
+		assertShellOutput(t, out,
+			"Gave the last win to Rand instead of Paul.\n",
+			"Took back a win from Rand.\n",
+			ErrNothingToUndo.Error()+"\n",
+		)
+	})
+	t.Run("passes pause and resume during a game to the game's clock", func(t *testing.T) {
+		game := &GameSpy{}
+		in := strings.NewReader("new-game Rand, Chris\npause\nresume\nRand wins\n")
+
+		NewShell(in, &bytes.Buffer{}, game, &StubPlayerStore{}).Run()
+
+		if strings.Join(game.ClockCommands, ",") != "pause,resume" {
+			t.Errorf("got clock commands %v", game.ClockCommands)
+		}
+		assertFinishedWith(t, game, "Rand")
+	})
+	t.Run("abandons a game and comes back to the prompt", func(t *testing.T) {
+		game := &GameSpy{}
+		out := &bytes.Buffer{}
+		in := strings.NewReader("new-game Rand, Chris\nhelp\nquit\nnew-game Paul, Cleo\nPaul wins\n")
+
+		NewShell(in, out, game, &StubPlayerStore{}).Run()
+
+		assertShellOutput(t, out, GameHelp+GameAbandonedMsg+"\n", "")
+		if !game.WasAbandoned() {
+			t.Error("expected the first game to be abandoned")
+		}
+		assertStartedWith(t, game, "Paul", "Cleo")
+		assertFinishedWith(t, game, "Paul")
+	})
+	t.Run("has no pause outside a game", func(t *testing.T) {
+		out := &bytes.Buffer{}
+
+		NewShell(strings.NewReader("pause\n"), out, &GameSpy{}, &StubPlayerStore{}).Run()
+
+		assertShellOutput(t, out, "Unknown command \"pause\", type help to see the commands.\n")
+	})
+}
+
+func assertShellOutput(t testing.TB, out *bytes.Buffer, responses ...string) {
+	t.Helper()
+	want := ShellPrompt + strings.Join(responses, ShellPrompt) + ShellPrompt
+	if got := out.String(); got != want {
+		t.Errorf("got shell output %q, want %q", got, want)
+	}
+}
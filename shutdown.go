@@ -0,0 +1,165 @@
+package poker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var ErrShuttingDown = errors.New("the server is shutting down")
+
+// ShutdownMessage is sent to every open WebSocket as the server shuts down,
+// in the same shape as a BlindAlertMessage.
+var ShutdownMessage = []byte(`{"Type":"shutdown"}`)
+
+// Shutdown stops the server taking new games or watchers, tells everyone
+// connected over a WebSocket that it is going away and closes their
+// connections, then waits for their handlers to finish, or ctx to be done.
+// It doesn't stop the HTTP server itself.
+func (p *PlayerServer) Shutdown(ctx context.Context) error {
+	p.connMu.Lock()
+	p.draining = true
+	sockets := make([]*playerServerWS, 0, len(p.sockets))
+	for ws := range p.sockets {
+		sockets = append(sockets, ws)
+	}
+	p.connMu.Unlock()
+
+	p.logger.Info("shutting down", "websockets", len(sockets))
+	for _, ws := range sockets {
+		ws.goAway()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("problem waiting for WebSocket handlers to finish, %v", ctx.Err())
+	}
+}
+
+func (p *PlayerServer) shuttingDown() bool {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	return p.draining
+}
+
+// refuseWhileShuttingDown turns away requests for new WebSockets once
+// Shutdown has been called.
+func (p *PlayerServer) refuseWhileShuttingDown(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.shuttingDown() {
+			w.Header().Set("connection", "close")
+			http.Error(w, ErrShuttingDown.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// openSocket registers ws so Shutdown can reach it, returning false if the
+// server has started shutting down since the request came in. Once it
+// returns true the returned function has to be called when the handler is
+// done with ws.
+func (p *PlayerServer) openSocket(ws *playerServerWS) (func(), bool) {
+	p.connMu.Lock()
+	if p.draining {
+		p.connMu.Unlock()
+		ws.goAway()
+		return nil, false
+	}
+	p.sockets[ws] = struct{}{}
+	p.handlers.Add(1)
+	p.connMu.Unlock()
+
+	return func() {
+		p.connMu.Lock()
+		delete(p.sockets, ws)
+		p.connMu.Unlock()
+		p.handlers.Done()
+	}, true
+}
+
+func (p *PlayerServer) checkAcceptingGames() (map[string]interface{}, error) {
+	if p.shuttingDown() {
+		return nil, ErrShuttingDown
+	}
+	return nil, nil
+}
+
+// goAway sends the ShutdownMessage then closes the connection, which ends
+// the handler reading from it.
+func (w *playerServerWS) goAway() {
+	w.Write(ShutdownMessage)
+	closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, ErrShuttingDown.Error())
+	w.WriteControl(websocket.CloseMessage, closing, time.Now().Add(time.Second))
+	w.Close()
+}
+
+// SaveGameState writes where game has got to as JSON to path, if it is a
+// StatefulGame with a game in progress, and reports whether it did. The file
+// is written to one side and renamed into place so it is never left half
+// written.
+func SaveGameState(path string, game Game) (bool, error) {
+	stateful, ok := game.(StatefulGame)
+	if !ok {
+		return false, nil
+	}
+	state, running := stateful.State()
+	if !running {
+		return false, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return false, fmt.Errorf("problem encoding game state, %v", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".game-state-*")
+	if err != nil {
+		return false, fmt.Errorf("problem creating game state file, %v", err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("problem writing game state, %v", err)
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return false, fmt.Errorf("problem saving game state to %s, %v", path, err)
+	}
+	return true, nil
+}
+
+// LoadGameState reads a GameState written by SaveGameState.
+func LoadGameState(path string) (GameState, error) {
+	var state GameState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, fmt.Errorf("problem reading game state, %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("problem parsing game state in %s, %v", path, err)
+	}
+	return state, nil
+}
@@ -0,0 +1,117 @@
+package poker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Run("tells WebSocket clients and waits for their games to stop", func(t *testing.T) {
+		game := &GameSpy{}
+		player, _ := NewPlayerServer(dummyPlayerStore, game, WithLogger(DiscardLogger))
+		server := httptest.NewServer(player)
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+		writeWSMessage(t, ws, "Paul, Rand")
+		assertWSMessage(t, ws, string(GameStartedMessage))
+		assertStartedWith(t, game, "Paul", "Rand")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assertNoError(t, player.Shutdown(ctx))
+
+		_, msg, err := ws.ReadMessage()
+		assertNoError(t, err)
+		assertResponseBody(t, string(msg), string(ShutdownMessage))
+
+		_, _, err = ws.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("got %v, want the connection closed as going away", err)
+		}
+		if game.WasAbandoned() {
+			t.Error("abandoned the game, want it left running to be saved")
+		}
+	})
+	t.Run("turns away new games and stops being ready", func(t *testing.T) {
+		server, _ := NewPlayerServer(dummyPlayerStore, dummyGame, WithLogger(DiscardLogger))
+		assertNoError(t, server.Shutdown(context.Background()))
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/ws", nil))
+		assertStatus(t, response, http.StatusServiceUnavailable)
+
+		response, report := getHealthReport(t, server, "/readyz")
+		assertStatus(t, response, http.StatusServiceUnavailable)
+		assertCheckStatus(t, report, "accepting_games", HealthFailing)
+	})
+}
+
+func TestSaveGameState(t *testing.T) {
+	t.Run("saves where a game in progress has got to", func(t *testing.T) {
+		clock := NewFakeClock()
+		game := NewTexasHoldemWithClock(NewScheduledAlerterWithClock(clock), &StubPlayerStore{}, clock)
+		game.Start([]string{"Chris", "Cleo"}, &strings.Builder{})
+		clock.Advance(8 * time.Minute)
+		game.Pause()
+
+		path := filepath.Join(t.TempDir(), "game.state.json")
+		saved, err := SaveGameState(path, game)
+		assertNoError(t, err)
+		if !saved {
+			t.Fatal("expected the game to be saved")
+		}
+
+		state, err := LoadGameState(path)
+		assertNoError(t, err)
+		want := GameState{
+			Players:   []string{"Chris", "Cleo"},
+			Level:     2,
+			Blind:     200,
+			Remaining: 6 * time.Minute,
+			Paused:    true,
+			Saved:     clock.Now(),
+		}
+		if strings.Join(state.Players, ",") != "Chris,Cleo" || state.Level != want.Level || state.Blind != want.Blind ||
+			state.Remaining != want.Remaining || state.Paused != want.Paused || !state.Saved.Equal(want.Saved) {
+			t.Errorf("got %+v, want %+v", state, want)
+		}
+	})
+	t.Run("saves nothing when no game is running", func(t *testing.T) {
+		game := NewTexasHoldem(NewScheduledAlerter(), &StubPlayerStore{})
+
+		path := filepath.Join(t.TempDir(), "game.state.json")
+		saved, err := SaveGameState(path, game)
+		assertNoError(t, err)
+		if saved {
+			t.Error("didn't expect a game to be saved")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected no state file, got %v", err)
+		}
+	})
+	t.Run("saves nothing once a game has been abandoned", func(t *testing.T) {
+		store := &StubPlayerStore{}
+		game := NewTexasHoldem(NewScheduledAlerter(), store)
+		game.Start([]string{"Chris", "Cleo"}, &strings.Builder{})
+		game.Abandon()
+
+		saved, err := SaveGameState(filepath.Join(t.TempDir(), "game.state.json"), game)
+		assertNoError(t, err)
+		if saved {
+			t.Error("saved an abandoned game")
+		}
+		if len(store.winCalls) != 0 {
+			t.Errorf("got wins %v recorded for an abandoned game", store.winCalls)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+const startGame = document.getElementById('game-start')
+
+const declareWinner = document.getElementById('declare-winner')
+const clockControls = document.getElementById('clock-controls')
+const submitWinnerButton = document.getElementById('winner-button')
+const winnerInput = document.getElementById('winner')
+
+const blindContainer = document.getElementById('blind-value')
+const nextBlindContainer = document.getElementById('next-blind')
+
+let nextBlind = null
+let nextBlindAt = null
+
+const formatCountdown = ms => {
+    const seconds = Math.max(0, Math.round(ms / 1000))
+    const minutes = Math.floor(seconds / 60)
+    return minutes + ':' + String(seconds % 60).padStart(2, '0')
+}
+
+setInterval(() => {
+    if (nextBlindAt === null) {
+        nextBlindContainer.innerText = ''
+        return
+    }
+    nextBlindContainer.innerText = 'Blinds go up to ' + nextBlind + ' in ' + formatCountdown(nextBlindAt - Date.now())
+}, 250)
+
+const gameContainer = document.getElementById('game')
+const gameEndContainer = document.getElementById('game-end')
+const errorContainer = document.getElementById('game-error')
+
+declareWinner.hidden = true
+clockControls.hidden = true
+gameEndContainer.hidden = true
+
+// The players and the winner are only taken as accepted once the server
+// says so. Until then anything it turns down is shown and can be tried again.
+const showStart = () => {
+    startGame.hidden = false
+    declareWinner.hidden = true
+    clockControls.hidden = true
+}
+
+const showGame = () => {
+    startGame.hidden = true
+    declareWinner.hidden = false
+    clockControls.hidden = false
+}
+
+let conn = null
+let finished = false
+
+const send = msg => {
+    if (conn !== null && conn.readyState === WebSocket.OPEN) {
+        conn.send(msg)
+    }
+}
+
+document.getElementById('start-game').addEventListener('click', event => {
+    const players = document.getElementById('players').value
+    errorContainer.innerText = ''
+
+    if (conn !== null) {
+        send(players)
+        return
+    }
+
+    if (window['WebSocket']) {
+        const scheme = document.location.protocol === 'https:' ? 'wss://' : 'ws://'
+        conn = new WebSocket(scheme + document.location.host + '/ws')
+
+        submitWinnerButton.onclick = event => {
+            errorContainer.innerText = ''
+            send(winnerInput.value)
+        }
+
+        clockControls.querySelectorAll('button').forEach(button => {
+            button.onclick = event => send(button.dataset.command)
+        })
+
+        conn.onclose = evt => {
+            conn = null
+            nextBlindAt = null
+            if (finished || evt.code === 1001) {
+                return
+            }
+            errorContainer.innerText = 'Connection closed'
+            showStart()
+        }
+
+        conn.onmessage = evt => {
+            let msg
+            try {
+                msg = JSON.parse(evt.data)
+            } catch (e) {
+                errorContainer.innerText = evt.data
+                return
+            }
+
+            if (msg.Type === 'started') {
+                showGame()
+            } else if (msg.Type === 'finished') {
+                finished = true
+                gameEndContainer.hidden = false
+                gameContainer.hidden = true
+            } else if (msg.Type === 'blind') {
+                blindContainer.innerText = 'Blind is now ' + msg.Amount
+                if (msg.Amount === nextBlind) {
+                    nextBlindAt = null
+                }
+            } else if (msg.Type === 'warning') {
+                nextBlind = msg.Amount
+                nextBlindAt = Date.now() + msg.In * 1000
+            } else if (msg.Type === 'clock') {
+                blindContainer.innerText = 'Blind is now ' + msg.Amount + (msg.Paused ? ' (paused)' : '')
+                nextBlind = msg.Next || null
+                nextBlindAt = msg.Paused || !msg.Next ? null : Date.now() + (msg.In || 0) * 1000
+            } else if (msg.Type === 'shutdown') {
+                blindContainer.innerText = 'The server is restarting, the game has been saved'
+                nextBlindAt = null
+            }
+        }
+
+        conn.onopen = function () {
+            conn.send(players)
+        }
+    }
+})
@@ -0,0 +1,127 @@
+package poker
+
+import (
+	"sync"
+	"testing"
+)
+
+// PlayerStoreFactory makes an empty store for one conformance check. For a
+// store that persists, reopen gives a new store over the same data once
+// the first is finished with; ephemeral stores return a nil reopen.
+type PlayerStoreFactory func(t *testing.T) (store PlayerStore, reopen func() PlayerStore)
+
+// PlayerStoreConformance checks that a PlayerStore implementation keeps the
+// contract the server and games rely on.
+func PlayerStoreConformance(t *testing.T, factory PlayerStoreFactory) {
+	t.Run("scores players it has never seen as zero", func(t *testing.T) {
+		store, _ := factory(t)
+
+		assertConformingScore(t, store, "Apollo", 0)
+		if league := store.GetLeague(); len(league) != 0 {
+			t.Errorf("got league %v for a new store, want it empty", league)
+		}
+	})
+	t.Run("adds new players to the league when they win", func(t *testing.T) {
+		store, _ := factory(t)
+		store.RecordWin("Paul")
+		store.RecordWin("Paul")
+
+		assertConformingScore(t, store, "Paul", 2)
+		assertConformingLeague(t, store.GetLeague(), []Player{{Name: "Paul", Wins: 2}})
+	})
+	t.Run("orders the league by wins", func(t *testing.T) {
+		store, _ := factory(t)
+		for _, winner := range []string{"Cleo", "Chris", "Chris", "Tiest", "Chris", "Tiest"} {
+			store.RecordWin(winner)
+		}
+
+		assertConformingLeague(t, store.GetLeague(), []Player{
+			{Name: "Chris", Wins: 3},
+			{Name: "Tiest", Wins: 2},
+			{Name: "Cleo", Wins: 1},
+		})
+	})
+	t.Run("counts games without counting wins", func(t *testing.T) {
+		store, _ := factory(t)
+		store.RecordAttendance("Paul")
+		store.RecordAttendance("Rand")
+		store.RecordWin("Rand")
+
+		assertConformingLeague(t, store.GetLeague(), []Player{
+			{Name: "Rand", Wins: 1, Games: 1},
+			{Name: "Paul", Games: 1},
+		})
+	})
+	t.Run("undoes wins from the most recent back", func(t *testing.T) {
+		store, _ := factory(t)
+		store.RecordWin("Paul")
+		store.RecordWin("Rand")
+
+		for _, want := range []string{"Rand", "Paul"} {
+			got, err := store.UndoLastWin()
+			if err != nil || got != want {
+				t.Errorf("got %q, %v from undo, want %q", got, err, want)
+			}
+		}
+		if _, err := store.UndoLastWin(); err != ErrNothingToUndo {
+			t.Errorf("got %v undoing with no wins left, want %v", err, ErrNothingToUndo)
+		}
+		assertConformingScore(t, store, "Paul", 0)
+	})
+	t.Run("is safe to use from many goroutines", func(t *testing.T) {
+		store, _ := factory(t)
+		const goroutines = 20
+
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store.RecordAttendance("Paul")
+				store.RecordWin("Paul")
+				store.GetLeague()
+				store.GetPlayerScore("Paul")
+			}()
+		}
+		wg.Wait()
+
+		assertConformingLeague(t, store.GetLeague(), []Player{{Name: "Paul", Wins: goroutines, Games: goroutines}})
+	})
+	t.Run("keeps everything when reopened", func(t *testing.T) {
+		store, reopen := factory(t)
+		if reopen == nil {
+			t.Skip("store does not persist")
+		}
+		store.RecordAttendance("Paul")
+		store.RecordWin("Paul")
+		store.RecordWin("Rand")
+		want := store.GetLeague()
+
+		reopened := reopen()
+
+		assertConformingLeague(t, reopened.GetLeague(), want)
+		if got, err := reopened.UndoLastWin(); err != nil || got != "Rand" {
+			t.Errorf("got %q, %v from undo after reopening, want Rand", got, err)
+		}
+	})
+}
+
+func assertConformingScore(t testing.TB, store PlayerStore, name string, want int) {
+	t.Helper()
+	if got := store.GetPlayerScore(name); got != want {
+		t.Errorf("got %d wins for %s, want %d", got, name, want)
+	}
+}
+
+func assertConformingLeague(t testing.TB, got, want League) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got league %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got league %v, want %v", got, want)
+			return
+		}
+	}
+}
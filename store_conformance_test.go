@@ -0,0 +1,53 @@
+package poker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayerStoreConformance(t *testing.T) {
+	t.Run("FileSystemPlayerStore", func(t *testing.T) {
+		PlayerStoreConformance(t, func(t *testing.T) (PlayerStore, func() PlayerStore) {
+			database, cleanDatabase := createTempFile(t, "")
+			t.Cleanup(cleanDatabase)
+			eventLog, cleanEventLog := createTempFile(t, "")
+			t.Cleanup(cleanEventLog)
+
+			store, err := NewFileSystemPlayerStoreWithLog(database, eventLog)
+			assertNoError(t, err)
+
+			return store, func() PlayerStore {
+				reopened, err := NewFileSystemPlayerStoreWithLog(database, eventLog)
+				assertNoError(t, err)
+				return reopened
+			}
+		})
+	})
+	t.Run("InMemoryPlayerStore", func(t *testing.T) {
+		PlayerStoreConformance(t, func(t *testing.T) (PlayerStore, func() PlayerStore) {
+			return NewInMemoryPlayerStore(), nil
+		})
+	})
+	t.Run("WriteBehindPlayerStore", func(t *testing.T) {
+		PlayerStoreConformance(t, func(t *testing.T) (PlayerStore, func() PlayerStore) {
+			behind := NewInMemoryPlayerStore()
+			store := NewWriteBehindPlayerStore(behind, time.Minute)
+			t.Cleanup(store.Close)
+
+			return store, func() PlayerStore {
+				store.Close()
+				return NewWriteBehindPlayerStore(behind, time.Minute)
+			}
+		})
+	})
+	t.Run("CachingPlayerStore", func(t *testing.T) {
+		PlayerStoreConformance(t, func(t *testing.T) (PlayerStore, func() PlayerStore) {
+			return NewCachingPlayerStore(NewInMemoryPlayerStore(), time.Minute), nil
+		})
+	})
+	t.Run("webhook store", func(t *testing.T) {
+		PlayerStoreConformance(t, func(t *testing.T) (PlayerStore, func() PlayerStore) {
+			return NewWebhookPlayerStore(NewInMemoryPlayerStore(), NewWebhooks(1, time.Millisecond)), nil
+		})
+	})
+}
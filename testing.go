@@ -1,14 +1,19 @@
 package poker
 
 import (
+	"fmt"
 	"io"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 type StubPlayerStore struct {
-	scores   map[string]int
-	winCalls []string
-	league   []Player
+	scores          map[string]int
+	winCalls        []string
+	league          []Player
+	attendanceCalls []string
 }
 
 func (s *StubPlayerStore) GetPlayerScore(name string) int {
@@ -20,7 +25,20 @@ func (s *StubPlayerStore) RecordWin(name string) {
 	s.winCalls = append(s.winCalls, name)
 }
 
-//server_test.go
+func (s *StubPlayerStore) UndoLastWin() (string, error) {
+	if len(s.winCalls) == 0 {
+		return "", ErrNothingToUndo
+	}
+	last := s.winCalls[len(s.winCalls)-1]
+	s.winCalls = s.winCalls[:len(s.winCalls)-1]
+	return last, nil
+}
+
+func (s *StubPlayerStore) RecordAttendance(name string) {
+	s.attendanceCalls = append(s.attendanceCalls, name)
+}
+
+// server_test.go
 func (s *StubPlayerStore) GetLeague() League {
 	return s.league
 }
@@ -38,16 +56,163 @@ func AssertPlayerWin(t testing.TB, store *StubPlayerStore, winner string) {
 	}
 }
 
+func AssertAttendance(t testing.TB, store *StubPlayerStore, players ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(store.attendanceCalls, players) {
+		t.Errorf("got attendance recorded for %v, want %v", store.attendanceCalls, players)
+	}
+}
+
 type GameSpy struct {
-	StartCalled  bool
-	StartedWith  int
-	FinishedWith string
+	mu            sync.Mutex
+	StartCalled   bool
+	StartedWith   []string
+	FinishedWith  string
+	ClockCommands []string
+	Abandoned     bool
 }
 
-func (g *GameSpy) Start(numberOfPlayers int, to io.Writer) {
+func (g *GameSpy) Start(players []string, to io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.StartCalled = true
-	g.StartedWith = numberOfPlayers
+	g.StartedWith = players
 }
-func (g *GameSpy) Finish(winner string) {
+func (g *GameSpy) Finish(winner string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.FinishedWith = winner
+	return nil
+}
+
+func (g *GameSpy) Abandon() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Abandoned = true
+}
+
+// WasAbandoned is Abandoned, safe to call while a server is still driving
+// the game.
+func (g *GameSpy) WasAbandoned() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Abandoned
+}
+
+func (g *GameSpy) Pause() error {
+	g.clockCommand("pause")
+	return nil
+}
+
+func (g *GameSpy) Resume() error {
+	g.clockCommand("resume")
+	return nil
+}
+
+func (g *GameSpy) NextLevel() error {
+	g.clockCommand("next")
+	return nil
+}
+
+func (g *GameSpy) PreviousLevel() error {
+	g.clockCommand("back")
+	return nil
+}
+
+func (g *GameSpy) AddTime(d time.Duration) error {
+	g.clockCommand(fmt.Sprintf("add %v", d))
+	return nil
+}
+
+func (g *GameSpy) clockCommand(command string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ClockCommands = append(g.ClockCommands, command)
+}
+
+// Calls returns what the game has been started with, finished with and
+// told to do with its clock so far, safe to call while a server is still
+// driving the game.
+func (g *GameSpy) Calls() (startedWith []string, finishedWith string, clockCommands []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.StartedWith, g.FinishedWith, append([]string(nil), g.ClockCommands...)
+}
+
+// FakeClock is a Clock that only moves when it is told to, firing any timers
+// that fall due on the way.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	f     func()
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2021, time.January, 1, 19, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock on by d, running each timer that falls due in the
+// order they would have fired. Advance(0) runs timers that are already due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+
+	for {
+		next := -1
+		for i, timer := range c.timers {
+			if !timer.at.After(target) && (next == -1 || timer.at.Before(c.timers[next].at)) {
+				next = i
+			}
+		}
+		if next == -1 {
+			break
+		}
+
+		timer := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		if timer.at.After(c.now) {
+			c.now = timer.at
+		}
+
+		c.mu.Unlock()
+		timer.f()
+		c.mu.Lock()
+	}
+
+	c.now = target
+	c.mu.Unlock()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,187 @@
+package poker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const selfSignedValidFor = 365 * 24 * time.Hour
+
+// TLSConfig is how the server speaks HTTPS. It is off unless there is a
+// certificate, either CertFile and KeyFile or one made up with SelfSigned.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// SelfSigned makes a certificate for LAN use, saved to CertFile and
+	// KeyFile if they are set so browsers only have to accept it once.
+	SelfSigned bool
+	// RedirectAddr is where to listen for plain HTTP and redirect it to
+	// HTTPS, or empty to not.
+	RedirectAddr string
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.SelfSigned
+}
+
+// Certificate loads the certificate to serve, making a self-signed one for
+// hosts if that's what the config asks for.
+func (c TLSConfig) Certificate(hosts []string, now time.Time) (tls.Certificate, error) {
+	if !c.SelfSigned {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return cert, fmt.Errorf("problem loading TLS certificate %s, %v", c.CertFile, err)
+		}
+		return cert, nil
+	}
+	if c.CertFile == "" {
+		certPEM, keyPEM, err := SelfSignedCertificate(hosts, now)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return tls.X509KeyPair(certPEM, keyPEM)
+	}
+	return loadOrCreateSelfSigned(c.CertFile, c.KeyFile, hosts, now)
+}
+
+// loadOrCreateSelfSigned reuses the certificate saved last time unless it
+// is missing, has run out or was made for other hosts.
+func loadOrCreateSelfSigned(certFile, keyFile string, hosts []string, now time.Time) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err == nil {
+		leaf, parseErr := x509.ParseCertificate(cert.Certificate[0])
+		if parseErr == nil && now.Before(leaf.NotAfter) && sameHosts(leaf, hosts) {
+			return cert, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return cert, fmt.Errorf("problem loading TLS certificate %s, %v", certFile, err)
+	}
+
+	certPEM, keyPEM, err := SelfSignedCertificate(hosts, now)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("problem saving TLS key %s, %v", keyFile, err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("problem saving TLS certificate %s, %v", certFile, err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// sameHosts is true when leaf covers exactly hosts, in any order.
+func sameHosts(leaf *x509.Certificate, hosts []string) bool {
+	var covered []string
+	covered = append(covered, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		covered = append(covered, ip.String())
+	}
+
+	var wanted []string
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		wanted = append(wanted, host)
+	}
+
+	sort.Strings(covered)
+	sort.Strings(wanted)
+	return strings.Join(covered, " ") == strings.Join(wanted, " ")
+}
+
+// SelfSignedCertificate makes a PEM encoded certificate and key for hosts,
+// which can be names or IP addresses, valid for a year from now.
+func SelfSignedCertificate(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem generating TLS key, %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem generating certificate serial number, %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Poker league"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem creating TLS certificate, %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem encoding TLS key, %v", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// LocalHosts are the names and addresses this machine might be reached by
+// on the LAN, for a self-signed certificate to cover.
+func LocalHosts() []string {
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil && name != "localhost" {
+		hosts = append(hosts, name)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return append(hosts, "127.0.0.1", "::1")
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			hosts = append(hosts, ipNet.IP.String())
+		}
+	}
+	return hosts
+}
+
+// RedirectToHTTPS sends every request on to the same path over HTTPS on the
+// port of httpsAddr.
+func RedirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
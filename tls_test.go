@@ -0,0 +1,172 @@
+package poker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Date(2021, time.January, 1, 19, 0, 0, 0, time.UTC)
+
+	t.Run("serves pages and WebSockets to clients that trust it", func(t *testing.T) {
+		certPEM, keyPEM, err := SelfSignedCertificate([]string{"localhost", "127.0.0.1"}, time.Now())
+		assertNoError(t, err)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		assertNoError(t, err)
+
+		server, _ := NewPlayerServer(&StubPlayerStore{}, &GameSpy{})
+		httpsServer := httptest.NewUnstartedServer(server)
+		httpsServer.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		httpsServer.StartTLS()
+		defer httpsServer.Close()
+
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(certPEM)
+		clientTLS := &tls.Config{RootCAs: roots}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		response, err := client.Get(httpsServer.URL + "/league")
+		assertNoError(t, err)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("got status %d over HTTPS, want %d", response.StatusCode, http.StatusOK)
+		}
+
+		dialer := websocket.Dialer{TLSClientConfig: clientTLS}
+		ws, _, err := dialer.Dial("wss"+strings.TrimPrefix(httpsServer.URL, "https")+"/ws", nil)
+		if err != nil {
+			t.Fatalf("could not open a wss connection, %v", err)
+		}
+		ws.Close()
+	})
+	t.Run("covers the names and addresses it is given", func(t *testing.T) {
+		certPEM, keyPEM, err := SelfSignedCertificate([]string{"poker.lan", "192.168.1.20"}, now)
+		assertNoError(t, err)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		assertNoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assertNoError(t, err)
+
+		for _, host := range []string{"poker.lan", "192.168.1.20"} {
+			if err := leaf.VerifyHostname(host); err != nil {
+				t.Errorf("certificate doesn't cover %s, %v", host, err)
+			}
+		}
+		if !leaf.NotAfter.Equal(now.Add(selfSignedValidFor)) {
+			t.Errorf("got certificate valid until %v, want a year from now", leaf.NotAfter)
+		}
+		if leaf.IsCA || leaf.KeyUsage&x509.KeyUsageCertSign != 0 {
+			t.Error("certificate can sign others, want it only good for this server")
+		}
+	})
+	t.Run("is kept in the files given and reused until it runs out", func(t *testing.T) {
+		dir := t.TempDir()
+		config := TLSConfig{
+			CertFile:   filepath.Join(dir, "cert.pem"),
+			KeyFile:    filepath.Join(dir, "key.pem"),
+			SelfSigned: true,
+		}
+
+		first, err := config.Certificate([]string{"localhost"}, now)
+		assertNoError(t, err)
+		again, err := config.Certificate([]string{"localhost"}, now.Add(time.Hour))
+		assertNoError(t, err)
+		if !bytes.Equal(first.Certificate[0], again.Certificate[0]) {
+			t.Error("made a new certificate, want the saved one reused")
+		}
+		if info, err := os.Stat(config.KeyFile); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("got key file %v %v, want it only readable by its owner", info, err)
+		}
+
+		renewed, err := config.Certificate([]string{"localhost"}, now.Add(2*selfSignedValidFor))
+		assertNoError(t, err)
+		if bytes.Equal(first.Certificate[0], renewed.Certificate[0]) {
+			t.Error("reused an expired certificate, want a new one")
+		}
+	})
+	t.Run("is made again when the hosts change", func(t *testing.T) {
+		dir := t.TempDir()
+		config := TLSConfig{
+			CertFile:   filepath.Join(dir, "cert.pem"),
+			KeyFile:    filepath.Join(dir, "key.pem"),
+			SelfSigned: true,
+		}
+
+		first, err := config.Certificate([]string{"localhost", "192.168.1.20"}, now)
+		assertNoError(t, err)
+		reordered, err := config.Certificate([]string{"192.168.1.20", "localhost"}, now)
+		assertNoError(t, err)
+		if !bytes.Equal(first.Certificate[0], reordered.Certificate[0]) {
+			t.Error("made a new certificate for the same hosts, want the saved one reused")
+		}
+
+		moved, err := config.Certificate([]string{"localhost", "192.168.1.21"}, now)
+		assertNoError(t, err)
+		leaf, err := x509.ParseCertificate(moved.Certificate[0])
+		assertNoError(t, err)
+		if err := leaf.VerifyHostname("192.168.1.21"); err != nil {
+			t.Errorf("kept a certificate for the old address, %v", err)
+		}
+	})
+	t.Run("reports a provided certificate that is missing", func(t *testing.T) {
+		config := TLSConfig{CertFile: "no-such-cert.pem", KeyFile: "no-such-key.pem"}
+
+		_, err := config.Certificate(nil, now)
+		if err == nil || !strings.Contains(err.Error(), "no-such-cert.pem") {
+			t.Errorf("got %v, want an error about no-such-cert.pem", err)
+		}
+	})
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cases := []struct {
+		httpsAddr, host, target, want string
+	}{
+		{":5443", "poker.lan", "/league?sort=wins", "https://poker.lan:5443/league?sort=wins"},
+		{":5443", "poker.lan:5080", "/game", "https://poker.lan:5443/game"},
+		{":443", "192.168.1.20:80", "/", "https://192.168.1.20/"},
+		{":443", "[::1]:80", "/", "https://[::1]/"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.host+c.target, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, c.target, nil)
+			request.Host = c.host
+			response := httptest.NewRecorder()
+
+			RedirectToHTTPS(c.httpsAddr).ServeHTTP(response, request)
+
+			assertStatus(t, response, http.StatusPermanentRedirect)
+			if got := response.Header().Get("Location"); got != c.want {
+				t.Errorf("got redirect to %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTLSConfigValidation(t *testing.T) {
+	config := DefaultConfig()
+	config.TLS = TLSConfig{CertFile: "cert.pem", RedirectAddr: ":80"}
+
+	err := config.Validate()
+
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidConfig)
+	}
+	for _, want := range []string{"TLS.KeyFile", "cert.pem"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package poker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrGameNotRunning  = errors.New("there is no game running")
+	ErrClockPaused     = errors.New("the clock is already paused")
+	ErrClockNotPaused  = errors.New("the clock is not paused")
+	ErrNoNextLevel     = errors.New("already at the last blind level")
+	ErrNoPreviousLevel = errors.New("already at the first blind level")
+)
+
+func (t *TexasHoldem) Pause() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return ErrGameNotRunning
+	}
+	t.catchUp()
+	if t.paused {
+		return ErrClockPaused
+	}
+
+	t.remaining = t.timeLeftInLevel()
+	t.paused = true
+	t.cancelAlerts()
+	t.reportStatus()
+	return nil
+}
+
+func (t *TexasHoldem) Resume() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return ErrGameNotRunning
+	}
+	if !t.paused {
+		return ErrClockNotPaused
+	}
+
+	t.paused = false
+	t.levelEnds = t.clock.Now().Add(t.remaining)
+	t.scheduleLevels(false)
+	t.reportStatus()
+	return nil
+}
+
+func (t *TexasHoldem) NextLevel() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return ErrGameNotRunning
+	}
+	t.catchUp()
+	if t.level+1 >= len(t.blinds) {
+		return ErrNoNextLevel
+	}
+
+	t.moveToLevel(t.level + 1)
+	return nil
+}
+
+func (t *TexasHoldem) PreviousLevel() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return ErrGameNotRunning
+	}
+	t.catchUp()
+	if t.level == 0 {
+		return ErrNoPreviousLevel
+	}
+
+	t.moveToLevel(t.level - 1)
+	return nil
+}
+
+// AddTime lengthens the current level by d, or shortens it if d is negative.
+func (t *TexasHoldem) AddTime(d time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return ErrGameNotRunning
+	}
+	t.catchUp()
+
+	t.remaining = t.timeLeftInLevel() + d
+	if t.remaining < 0 {
+		t.remaining = 0
+	}
+	t.levelEnds = t.clock.Now().Add(t.remaining)
+	t.scheduleLevels(false)
+	t.reportStatus()
+	return nil
+}
+
+// State is where the game has got to, if one is running.
+func (t *TexasHoldem) State() (GameState, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return GameState{}, false
+	}
+	t.catchUp()
+	return GameState{
+		Players:   append([]string{}, t.players...),
+		Level:     t.level + 1,
+		Blind:     t.blinds[t.level],
+		Remaining: t.timeLeftInLevel(),
+		Paused:    t.paused,
+		Saved:     t.clock.Now(),
+	}, true
+}
+
+// catchUp moves the current level on past any level changes that have
+// happened since the clock was last touched.
+func (t *TexasHoldem) catchUp() {
+	if t.paused {
+		return
+	}
+
+	now := t.clock.Now()
+	for t.level+1 < len(t.blinds) && !now.Before(t.levelEnds) {
+		t.level++
+		t.levelEnds = t.levelEnds.Add(t.levelLength)
+	}
+}
+
+func (t *TexasHoldem) moveToLevel(level int) {
+	t.level = level
+	t.remaining = t.levelLength
+	t.levelEnds = t.clock.Now().Add(t.remaining)
+	t.scheduleLevels(true)
+	t.reportStatus()
+}
+
+func (t *TexasHoldem) timeLeftInLevel() time.Duration {
+	if t.paused {
+		return t.remaining
+	}
+	if left := t.levelEnds.Sub(t.clock.Now()); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// scheduleLevels replaces any pending alerts with ones for the rest of the
+// game, counting from the time left in the current level. When announce is
+// set the current level is alerted straight away, as on a level change.
+func (t *TexasHoldem) scheduleLevels(announce bool) {
+	t.cancelAlerts()
+
+	if announce {
+		t.alerter.ScheduledAlertAt(0, t.blinds[t.level], t.to)
+	}
+	if t.paused {
+		return
+	}
+
+	levelStart, levelLength := time.Duration(0), t.remaining
+	for i := t.level + 1; i < len(t.blinds); i++ {
+		t.scheduleWarnings(levelStart, levelLength, t.blinds[i])
+		levelStart += levelLength
+		t.alerter.ScheduledAlertAt(levelStart, t.blinds[i], t.to)
+		levelLength = t.levelLength
+	}
+}
+
+func (t *TexasHoldem) scheduleWarnings(levelStart, levelLength time.Duration, nextBlind int) {
+	warner, ok := t.alerter.(BlindWarner)
+	if !ok {
+		return
+	}
+
+	warner.ScheduledWarningAt(levelStart, BlindWarning{Amount: nextBlind, In: levelLength}, t.to)
+	for _, before := range t.warnings {
+		if before > 0 && before < levelLength {
+			warner.ScheduledWarningAt(levelStart+levelLength-before, BlindWarning{Amount: nextBlind, In: before}, t.to)
+		}
+	}
+}
+
+func (t *TexasHoldem) cancelAlerts() {
+	if canceller, ok := t.alerter.(AlertCanceller); ok {
+		canceller.CancelAlerts()
+	}
+}
+
+func (t *TexasHoldem) reportStatus() {
+	t.logger.Info("blind clock changed", "level", t.level+1, "amount", t.blinds[t.level], "remaining", t.timeLeftInLevel(), "paused", t.paused)
+
+	reporter, ok := t.alerter.(StatusReporter)
+	if !ok {
+		return
+	}
+
+	status := ClockStatus{
+		Paused:    t.paused,
+		Amount:    t.blinds[t.level],
+		Remaining: t.timeLeftInLevel(),
+	}
+	if t.level+1 < len(t.blinds) {
+		status.NextAmount = t.blinds[t.level+1]
+	}
+	reporter.ReportStatus(status, t.to)
+}
+
+// RunClockCommand applies line to clock if it is one of the clock commands
+// players can type during a game: pause, resume, next, back or add DURATION.
+// It reports whether line was a clock command at all.
+func RunClockCommand(clock BlindClock, line string) (bool, error) {
+	fields := strings.Fields(line)
+
+	switch {
+	case len(fields) == 1 && fields[0] == "pause":
+		return true, clock.Pause()
+	case len(fields) == 1 && fields[0] == "resume":
+		return true, clock.Resume()
+	case len(fields) == 1 && fields[0] == "next":
+		return true, clock.NextLevel()
+	case len(fields) == 1 && fields[0] == "back":
+		return true, clock.PreviousLevel()
+	case len(fields) == 2 && fields[0] == "add":
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return true, fmt.Errorf("could not understand %q as an amount of time, try something like 5m", fields[1])
+		}
+		return true, clock.AddTime(d)
+	}
+	return false, nil
+}
@@ -0,0 +1,359 @@
+package poker
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	EventGameStarted  = "game.started"
+	EventBlindChanged = "blind.changed"
+	EventBlindWarning = "blind.warning"
+	EventWinRecorded  = "win.recorded"
+)
+
+const webhookSignatureHeader = "X-Poker-Signature"
+const webhookEventHeader = "X-Poker-Event"
+const maxWebhookDeliveries = 100
+
+var webhookEvents = []string{EventGameStarted, EventBlindChanged, EventBlindWarning, EventWinRecorded}
+
+type WebhookSubscription struct {
+	ID     string
+	URL    string
+	Events []string
+	Secret string `json:",omitempty"`
+}
+
+func (s WebhookSubscription) wants(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	SubscriptionID string
+	Event          string
+	Attempt        int
+	StatusCode     int
+	Error          string `json:",omitempty"`
+	Time           time.Time
+}
+
+type WebhookPayload struct {
+	Event string
+	Time  time.Time
+	Data  interface{}
+}
+
+type Webhooks struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	mu            sync.Mutex
+	subscriptions map[string]WebhookSubscription
+	deliveries    []WebhookDelivery
+	nextID        int
+	inFlight      sync.WaitGroup
+}
+
+func NewWebhooks(maxAttempts int, backoff time.Duration) *Webhooks {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Webhooks{
+		client:        &http.Client{Timeout: 5 * time.Second},
+		maxAttempts:   maxAttempts,
+		backoff:       backoff,
+		subscriptions: map[string]WebhookSubscription{},
+	}
+}
+
+func (w *Webhooks) Subscribe(sub WebhookSubscription) (WebhookSubscription, error) {
+	if err := validateWebhookSubscription(sub); err != nil {
+		return WebhookSubscription{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextID++
+	sub.ID = strconv.Itoa(w.nextID)
+	w.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (w *Webhooks) Unsubscribe(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.subscriptions[id]
+	delete(w.subscriptions, id)
+	return ok
+}
+
+// Subscriptions lists the current subscriptions in creation order, with
+// their secrets removed so they can be shown to clients.
+func (w *Webhooks) Subscriptions() []WebhookSubscription {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	subs := make([]WebhookSubscription, 0, len(w.subscriptions))
+	for i := 1; i <= w.nextID; i++ {
+		if sub, ok := w.subscriptions[strconv.Itoa(i)]; ok {
+			sub.Secret = ""
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+func (w *Webhooks) Deliveries(id string) ([]WebhookDelivery, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.subscriptions[id]; !ok {
+		return nil, false
+	}
+
+	deliveries := []WebhookDelivery{}
+	for _, d := range w.deliveries {
+		if d.SubscriptionID == id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, true
+}
+
+// Notify sends event to every interested subscriber in the background.
+func (w *Webhooks) Notify(event string, data interface{}) {
+	body, err := json.Marshal(WebhookPayload{Event: event, Time: time.Now().UTC(), Data: data})
+	if err != nil {
+		DefaultLogger.Error("problem encoding webhook payload", "event", event, "err", err)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, sub := range w.subscriptions {
+		if !sub.wants(event) {
+			continue
+		}
+		w.inFlight.Add(1)
+		go w.deliver(sub, event, body)
+	}
+}
+
+// Wait blocks until every delivery started so far has succeeded or run out
+// of retries.
+func (w *Webhooks) Wait() {
+	w.inFlight.Wait()
+}
+
+func (w *Webhooks) Alert(amount int, to io.Writer) {
+	w.Notify(EventBlindChanged, struct{ Amount int }{amount})
+}
+
+func (w *Webhooks) Warn(warning BlindWarning, to io.Writer) {
+	w.Notify(EventBlindWarning, struct{ Amount, In int }{warning.Amount, int(warning.In / time.Second)})
+}
+
+func (w *Webhooks) deliver(sub WebhookSubscription, event string, body []byte) {
+	defer w.inFlight.Done()
+
+	wait := w.backoff
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		statusCode, err := w.post(sub, event, body)
+		w.logDelivery(sub.ID, event, attempt, statusCode, err)
+
+		if err == nil {
+			return
+		}
+		if attempt < w.maxAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+}
+
+func (w *Webhooks) post(sub WebhookSubscription, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("content-type", jsonContentType)
+	req.Header.Set(webhookEventHeader, event)
+	if sub.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, SignWebhookPayload(sub.Secret, body))
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded with %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func (w *Webhooks) logDelivery(id, event string, attempt, statusCode int, err error) {
+	delivery := WebhookDelivery{
+		SubscriptionID: id,
+		Event:          event,
+		Attempt:        attempt,
+		StatusCode:     statusCode,
+		Time:           time.Now().UTC(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.deliveries = append(w.deliveries, delivery)
+	if len(w.deliveries) > maxWebhookDeliveries {
+		w.deliveries = w.deliveries[len(w.deliveries)-maxWebhookDeliveries:]
+	}
+}
+
+// SignWebhookPayload returns the value of the signature header a receiver
+// should expect for body when the subscription was created with secret.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateWebhookSubscription(sub WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url %q must be an absolute http(s) url", sub.URL)
+	}
+
+	for _, event := range sub.Events {
+		if !isWebhookEvent(event) {
+			return fmt.Errorf("unknown webhook event %q", event)
+		}
+	}
+	return nil
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type webhookPlayerStore struct {
+	PlayerStore
+	wrappedStore
+	hooks *Webhooks
+}
+
+// NewWebhookPlayerStore wraps store so that every recorded win is announced
+// to the webhook subscribers, along with whether it changed the league leader.
+func NewWebhookPlayerStore(store PlayerStore, hooks *Webhooks) PlayerStore {
+	return &webhookPlayerStore{store, wrappedStore{store}, hooks}
+}
+
+func (w *webhookPlayerStore) RecordWin(name string) {
+	leaderBefore := leaderOf(w.PlayerStore.GetLeague())
+	w.PlayerStore.RecordWin(name)
+	leaderAfter := leaderOf(w.PlayerStore.GetLeague())
+
+	w.hooks.Notify(EventWinRecorded, struct {
+		Player    string
+		Wins      int
+		Leader    string
+		NewLeader bool
+	}{
+		Player:    name,
+		Wins:      w.PlayerStore.GetPlayerScore(name),
+		Leader:    leaderAfter,
+		NewLeader: leaderAfter == name && leaderBefore != name,
+	})
+}
+
+// ImportPlayers passes a batch import through without announcing each win.
+func (w *webhookPlayerStore) ImportPlayers(players []Player) error {
+	return ImportPlayers(w.PlayerStore, players)
+}
+
+func leaderOf(league League) string {
+	if len(league) == 0 {
+		return ""
+	}
+	return league[0].Name
+}
+
+type webhookGame struct {
+	Game
+	hooks *Webhooks
+}
+
+// webhookClockGame is a webhookGame whose game has a blind clock, which it
+// keeps visible to the server's clock commands.
+type webhookClockGame struct {
+	*webhookGame
+	BlindClock
+}
+
+// NewWebhookGame wraps game so that starting it is announced to the webhook
+// subscribers.
+func NewWebhookGame(game Game, hooks *Webhooks) Game {
+	wrapped := &webhookGame{game, hooks}
+	if clock, ok := game.(BlindClock); ok {
+		return &webhookClockGame{wrapped, clock}
+	}
+	return wrapped
+}
+
+func (w *webhookGame) Abandon() {
+	if abandonable, ok := w.Game.(AbandonableGame); ok {
+		abandonable.Abandon()
+	}
+}
+
+func (w *webhookGame) State() (GameState, bool) {
+	if stateful, ok := w.Game.(StatefulGame); ok {
+		return stateful.State()
+	}
+	return GameState{}, false
+}
+
+func (w *webhookGame) SetLogger(logger *Logger) {
+	if loggingGame, ok := w.Game.(LoggingGame); ok {
+		loggingGame.SetLogger(logger)
+	}
+}
+
+func (w *webhookGame) Start(players []string, to io.Writer) {
+	w.Game.Start(players, to)
+	w.hooks.Notify(EventGameStarted, struct{ Players []string }{players})
+}
@@ -0,0 +1,191 @@
+package poker
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	t.Run("delivers signed win events to subscribers", func(t *testing.T) {
+		receiver := &spyWebhookReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		hooks := NewWebhooks(1, time.Millisecond)
+		_, err := hooks.Subscribe(WebhookSubscription{URL: server.URL, Events: []string{EventWinRecorded}, Secret: "s3cret"})
+		assertNoError(t, err)
+
+		store := NewWebhookPlayerStore(&StubPlayerStore{}, hooks)
+		store.RecordWin("Chris")
+		hooks.Wait()
+
+		if len(receiver.bodies) != 1 {
+			t.Fatalf("got %d deliveries, want 1", len(receiver.bodies))
+		}
+
+		body := receiver.bodies[0]
+		if got, want := receiver.signatures[0], SignWebhookPayload("s3cret", body); got != want {
+			t.Errorf("got signature %q, want %q", got, want)
+		}
+
+		var payload struct {
+			Event string
+			Data  struct{ Player string }
+		}
+		json.Unmarshal(body, &payload)
+		if payload.Event != EventWinRecorded || payload.Data.Player != "Chris" {
+			t.Errorf("unexpected payload %s", body)
+		}
+	})
+	t.Run("only sends events a subscriber asked for", func(t *testing.T) {
+		receiver := &spyWebhookReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		hooks := NewWebhooks(1, time.Millisecond)
+		hooks.Subscribe(WebhookSubscription{URL: server.URL, Events: []string{EventBlindChanged}})
+
+		NewWebhookGame(dummyGame, hooks).Start([]string{"Paul", "Rand"}, io.Discard)
+		hooks.Wait()
+
+		if len(receiver.bodies) != 0 {
+			t.Errorf("got %d deliveries, want none", len(receiver.bodies))
+		}
+	})
+	t.Run("keeps the game's blind clock reachable", func(t *testing.T) {
+		game := &GameSpy{}
+		clock, ok := NewWebhookGame(game, NewWebhooks(1, time.Millisecond)).(BlindClock)
+		if !ok {
+			t.Fatal("the wrapped game has lost its blind clock")
+		}
+
+		clock.Pause()
+		if len(game.ClockCommands) != 1 {
+			t.Errorf("got clock commands %v, want the pause passed on", game.ClockCommands)
+		}
+	})
+	t.Run("retries failed deliveries and logs each attempt", func(t *testing.T) {
+		receiver := &spyWebhookReceiver{failures: 2}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		hooks := NewWebhooks(3, time.Millisecond)
+		sub, _ := hooks.Subscribe(WebhookSubscription{URL: server.URL})
+
+		hooks.Notify(EventGameStarted, nil)
+		hooks.Wait()
+
+		deliveries, _ := hooks.Deliveries(sub.ID)
+		if len(deliveries) != 3 {
+			t.Fatalf("got %d delivery attempts, want 3", len(deliveries))
+		}
+		if deliveries[0].StatusCode != http.StatusInternalServerError || deliveries[2].StatusCode != http.StatusOK {
+			t.Errorf("unexpected delivery log %+v", deliveries)
+		}
+	})
+	t.Run("rejects subscriptions to unknown events", func(t *testing.T) {
+		hooks := NewWebhooks(1, time.Millisecond)
+		_, err := hooks.Subscribe(WebhookSubscription{URL: "http://example.com", Events: []string{"game.exploded"}})
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	t.Run("subscribe, list and unsubscribe with the admin token", func(t *testing.T) {
+		hooks := NewWebhooks(1, time.Millisecond)
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithWebhooks(hooks), WithAdminToken("s3cret"))
+
+		response := serveWebhookRequest(server, http.MethodPost, "/webhooks", `{"URL": "http://example.com/hook", "Secret": "s3cret"}`, "s3cret")
+		assertStatus(t, response, http.StatusCreated)
+
+		response = serveWebhookRequest(server, http.MethodGet, "/webhooks", "", "s3cret")
+		var subs []WebhookSubscription
+		json.NewDecoder(response.Body).Decode(&subs)
+		if len(subs) != 1 || subs[0].Secret != "" {
+			t.Fatalf("expected one subscription without its secret, got %+v", subs)
+		}
+
+		response = serveWebhookRequest(server, http.MethodDelete, "/webhooks/"+subs[0].ID, "", "s3cret")
+		assertStatus(t, response, http.StatusNoContent)
+
+		response = serveWebhookRequest(server, http.MethodGet, "/webhooks/"+subs[0].ID+"/deliveries", "", "s3cret")
+		assertStatus(t, response, http.StatusNotFound)
+	})
+	t.Run("need the admin token", func(t *testing.T) {
+		hooks := NewWebhooks(1, time.Millisecond)
+		sub, _ := hooks.Subscribe(WebhookSubscription{URL: "http://example.com/hook"})
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithWebhooks(hooks), WithAdminToken("s3cret"))
+
+		for _, request := range []struct{ method, path, body string }{
+			{http.MethodPost, "/webhooks", `{"URL": "http://169.254.169.254/"}`},
+			{http.MethodGet, "/webhooks", ""},
+			{http.MethodDelete, "/webhooks/" + sub.ID, ""},
+			{http.MethodGet, "/webhooks/" + sub.ID + "/deliveries", ""},
+		} {
+			response := serveWebhookRequest(server, request.method, request.path, request.body, "guess")
+			assertStatus(t, response, http.StatusUnauthorized)
+		}
+		if subs := hooks.Subscriptions(); len(subs) != 1 || subs[0].ID != sub.ID {
+			t.Errorf("got subscriptions %+v, want only the one there was", subs)
+		}
+	})
+	t.Run("are not served without an admin token", func(t *testing.T) {
+		server, _ := NewPlayerServer(&StubPlayerStore{}, dummyGame, WithWebhooks(NewWebhooks(1, time.Millisecond)))
+
+		response := serveWebhookRequest(server, http.MethodGet, "/webhooks", "", "")
+		assertStatus(t, response, http.StatusNotFound)
+	})
+}
+
+func serveWebhookRequest(server http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+type spyWebhookReceiver struct {
+	mu         sync.Mutex
+	failures   int
+	bodies     [][]byte
+	signatures []string
+}
+
+func (s *spyWebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	s.bodies = append(s.bodies, body)
+	s.signatures = append(s.signatures, r.Header.Get(webhookSignatureHeader))
+}
+
+func newTestWebhooks(t testing.TB, receiver *spyWebhookReceiver) *Webhooks {
+	t.Helper()
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	hooks := NewWebhooks(1, time.Millisecond)
+	if _, err := hooks.Subscribe(WebhookSubscription{URL: server.URL}); err != nil {
+		t.Fatalf("could not subscribe to webhooks %v", err)
+	}
+	return hooks
+}
@@ -0,0 +1,24 @@
+package poker
+
+// wrappedStore is embedded by stores that wrap another, to pass on the
+// optional interfaces of the store inside that the wrapper leaves alone.
+type wrappedStore struct {
+	inner PlayerStore
+}
+
+// Events returns the wrapped store's history, or nil if it keeps none.
+func (s wrappedStore) Events() []Event {
+	if source, ok := s.inner.(EventSource); ok {
+		return source.Events()
+	}
+	return nil
+}
+
+// CheckStore checks on the wrapped store, which is fine if it has nothing
+// to check.
+func (s wrappedStore) CheckStore() error {
+	if checker, ok := s.inner.(StoreChecker); ok {
+		return checker.CheckStore()
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package poker
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type pendingWrite struct {
+	win  bool
+	name string
+}
+
+// WriteBehindPlayerStore answers from its own copy of the league and
+// passes changes on to the store behind it in batches, every interval and
+// on Flush or Close. Wins still waiting to be written are undone without
+// troubling the store behind.
+type WriteBehindPlayerStore struct {
+	wrappedStore
+	store PlayerStore
+	clock Clock
+
+	mu      sync.Mutex
+	league  League
+	pending []pendingWrite
+	timer   Timer
+	closed  bool
+
+	flushMu sync.Mutex
+}
+
+func NewWriteBehindPlayerStore(store PlayerStore, interval time.Duration) *WriteBehindPlayerStore {
+	return NewWriteBehindPlayerStoreWithClock(SystemClock, store, interval)
+}
+
+func NewWriteBehindPlayerStoreWithClock(clock Clock, store PlayerStore, interval time.Duration) *WriteBehindPlayerStore {
+	w := &WriteBehindPlayerStore{
+		wrappedStore: wrappedStore{store},
+		store:        store,
+		clock:        clock,
+		league:       append(League{}, store.GetLeague()...),
+	}
+
+	var next func()
+	next = func() {
+		w.Flush()
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.closed {
+			w.timer = clock.AfterFunc(interval, next)
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer = clock.AfterFunc(interval, next)
+
+	return w
+}
+
+func (w *WriteBehindPlayerStore) GetPlayerScore(name string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if player := w.league.Find(name); player != nil {
+		return player.Wins
+	}
+	return 0
+}
+
+func (w *WriteBehindPlayerStore) GetLeague() League {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	league := append(League{}, w.league...)
+	sort.SliceStable(league, func(i, j int) bool {
+		return league[i].Wins > league[j].Wins
+	})
+	return league
+}
+
+func (w *WriteBehindPlayerStore) RecordWin(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.league = w.league.Apply(Event{Type: EventWinRecordedType, Player: name})
+	w.pending = append(w.pending, pendingWrite{win: true, name: name})
+}
+
+func (w *WriteBehindPlayerStore) RecordAttendance(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.league = w.league.Apply(Event{Type: EventAttendanceRecordedType, Player: name})
+	w.pending = append(w.pending, pendingWrite{name: name})
+}
+
+func (w *WriteBehindPlayerStore) UndoLastWin() (string, error) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := len(w.pending) - 1; i >= 0; i-- {
+		if w.pending[i].win {
+			name := w.pending[i].name
+			w.pending = append(w.pending[:i], w.pending[i+1:]...)
+			w.league = w.league.Apply(Event{Type: EventWinRevertedType, Player: name})
+			return name, nil
+		}
+	}
+
+	// The last win has already been written, so everything before it has to
+	// be too before the store behind can take it back. The lock is held until
+	// it has, so a win recorded meanwhile can't be the one undone instead.
+	w.write(w.takePending())
+
+	name, err := w.store.UndoLastWin()
+	if err != nil {
+		return "", err
+	}
+	w.league = w.league.Apply(Event{Type: EventWinRevertedType, Player: name})
+	return name, nil
+}
+
+// ImportPlayers writes everything pending, so the import lands after it,
+// then passes the batch on and picks up the league it makes.
+func (w *WriteBehindPlayerStore) ImportPlayers(players []Player) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.write(w.takePending())
+	err := ImportPlayers(w.store, players)
+	w.league = append(League{}, w.store.GetLeague()...)
+	return err
+}
+
+// Backup flushes, so the backup includes every change made so far, then
+// backs up the store behind.
+func (w *WriteBehindPlayerStore) Backup(out io.Writer) error {
+	source, ok := w.store.(BackupSource)
+	if !ok {
+		return errors.New("this store can't be backed up")
+	}
+	w.Flush()
+	return source.Backup(out)
+}
+
+// Flush writes every pending change to the store behind.
+func (w *WriteBehindPlayerStore) Flush() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.flush()
+}
+
+func (w *WriteBehindPlayerStore) flush() {
+	w.mu.Lock()
+	pending := w.takePending()
+	w.mu.Unlock()
+
+	w.write(pending)
+}
+
+func (w *WriteBehindPlayerStore) takePending() []pendingWrite {
+	pending := w.pending
+	w.pending = nil
+	return pending
+}
+
+func (w *WriteBehindPlayerStore) write(pending []pendingWrite) {
+	for _, write := range pending {
+		if write.win {
+			w.store.RecordWin(write.name)
+		} else {
+			w.store.RecordAttendance(write.name)
+		}
+	}
+}
+
+// Events flushes, so the history includes every change made so far, then
+// returns the store behind's events if it keeps them.
+func (w *WriteBehindPlayerStore) Events() []Event {
+	source, ok := w.store.(EventSource)
+	if !ok {
+		return nil
+	}
+	w.Flush()
+	return source.Events()
+}
+
+// Close stops the regular writes and flushes whatever is still pending.
+func (w *WriteBehindPlayerStore) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.timer.Stop()
+	w.mu.Unlock()
+
+	w.Flush()
+}
@@ -0,0 +1,70 @@
+package poker
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteBehindPlayerStore(t *testing.T) {
+	t.Run("writes changes to the store behind in batches", func(t *testing.T) {
+		clock := NewFakeClock()
+		behind := NewInMemoryPlayerStore()
+		store := NewWriteBehindPlayerStoreWithClock(clock, behind, time.Second)
+
+		store.RecordAttendance("Paul")
+		store.RecordWin("Paul")
+
+		assertPlayerScore(t, store.GetPlayerScore("Paul"), 1)
+		assertPlayerScore(t, behind.GetPlayerScore("Paul"), 0)
+
+		clock.Advance(time.Second)
+		assertLeague(t, behind.GetLeague(), []Player{{Name: "Paul", Wins: 1, Games: 1}})
+
+		store.RecordWin("Rand")
+		store.Close()
+		assertPlayerScore(t, behind.GetPlayerScore("Rand"), 1)
+	})
+	t.Run("undoes wins whether or not they've been written", func(t *testing.T) {
+		clock := NewFakeClock()
+		behind := NewInMemoryPlayerStore()
+		behind.RecordWin("Chris")
+		store := NewWriteBehindPlayerStoreWithClock(clock, behind, time.Second)
+
+		store.RecordWin("Paul")
+		assertUndone(t, store, "Paul")
+		assertUndone(t, store, "Chris")
+
+		clock.Advance(time.Second)
+		assertPlayerScore(t, behind.GetPlayerScore("Chris"), 0)
+		assertPlayerScore(t, behind.GetPlayerScore("Paul"), 0)
+	})
+	t.Run("backs up and imports after the changes still pending", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, "")
+		defer cleanDatabase()
+		behind, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+		store := NewWriteBehindPlayerStoreWithClock(NewFakeClock(), behind, time.Second)
+
+		store.RecordWin("Paul")
+		assertNoError(t, ImportPlayers(store, []Player{{Name: "Rand", Wins: 2, Games: 3}}))
+
+		var out bytes.Buffer
+		assertNoError(t, store.Backup(&out))
+		var backup StoreBackup
+		assertNoError(t, json.NewDecoder(&out).Decode(&backup))
+
+		want := []Player{{Name: "Paul", Wins: 1}, {Name: "Rand", Wins: 2, Games: 3}}
+		assertLeague(t, sortedByName(backup.League), want)
+		assertLeague(t, sortedByName(store.GetLeague()), want)
+	})
+}
+
+func assertUndone(t testing.TB, store PlayerStore, want string) {
+	t.Helper()
+	got, err := store.UndoLastWin()
+	if err != nil || got != want {
+		t.Errorf("got %q, %v from undo, want %q", got, err, want)
+	}
+}